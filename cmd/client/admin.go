@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	adminpb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/admin"
+	pb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/jsonrpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+type adminCmd struct {
+	Token   string        `env:"MCP_ADMIN_TOKEN" help:"Bearer token for the admin service"`
+	Timeout time.Duration `default:"10s" help:"Timeout of the admin call"`
+
+	Sessions  adminSessionsCmd  `cmd:"" help:"List the live sessions with their in-flight requests"`
+	Cancel    adminCancelCmd    `cmd:"" help:"Answer an in-flight request with an error and cancel its handler"`
+	Terminate adminTerminateCmd `cmd:"" help:"End the stream of a session"`
+}
+
+type adminSessionsCmd struct {
+	JSON bool `help:"Print the sessions as JSON"`
+}
+
+type adminCancelCmd struct {
+	Session string `arg:"" help:"ID of the session"`
+	Request string `arg:"" help:"JSON-RPC ID of the request; numeric IDs are matched as numbers"`
+	Reason  string `help:"Error message sent to the client"`
+}
+
+type adminTerminateCmd struct {
+	Session string `arg:"" help:"ID of the session"`
+	Reason  string `help:"Reason reported to the client"`
+}
+
+// call connects to the admin service and runs fn with the call timeout
+func (c *adminCmd) call(g *Globals, fn func(ctx context.Context, client adminpb.MCPAdminClient) error) error {
+	conn, err := g.dial()
+	if err != nil {
+		return fmt.Errorf("did not connect: %w", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+	if c.Token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+c.Token)
+	}
+	return fn(ctx, adminpb.NewMCPAdminClient(conn))
+}
+
+func (c *adminSessionsCmd) Run(g *Globals, a *adminCmd) error {
+	return a.call(g, func(ctx context.Context, client adminpb.MCPAdminClient) error {
+		resp, err := client.ListSessions(ctx, &adminpb.ListSessionsRequest{})
+		if err != nil {
+			return err
+		}
+		if c.JSON {
+			b, err := protojson.MarshalOptions{Multiline: true}.Marshal(resp)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(b))
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "SESSION\tPRINCIPAL\tPEER\tCLIENT\tPROTOCOL\tAGE\tRECEIVED\tSENT\tIN-FLIGHT")
+		for _, s := range resp.Sessions {
+			client := "-"
+			if ci := s.ClientInfo; ci != nil {
+				client = ci.Name + " " + ci.Version
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%d\t%d\n",
+				s.Id, orDash(s.Principal), orDash(s.Peer), client, orDash(s.ProtocolVersion),
+				since(s.StartedAt.AsTime()), s.MessagesReceived, s.MessagesSent, len(s.InflightRequests))
+			for _, r := range s.InflightRequests {
+				fmt.Fprintf(w, "  request %s\t%s %s\t\t\t\t%s\t\t\t\n", formatID(r.Id), r.Method, r.Tool, since(r.StartedAt.AsTime()))
+			}
+		}
+		return w.Flush()
+	})
+}
+
+func (c *adminCancelCmd) Run(g *Globals, a *adminCmd) error {
+	return a.call(g, func(ctx context.Context, client adminpb.MCPAdminClient) error {
+		_, err := client.CancelRequest(ctx, &adminpb.CancelRequestRequest{
+			SessionId: c.Session,
+			RequestId: parseID(c.Request),
+			Reason:    c.Reason,
+		})
+		return err
+	})
+}
+
+func (c *adminTerminateCmd) Run(g *Globals, a *adminCmd) error {
+	return a.call(g, func(ctx context.Context, client adminpb.MCPAdminClient) error {
+		_, err := client.TerminateSession(ctx, &adminpb.TerminateSessionRequest{
+			SessionId: c.Session,
+			Reason:    c.Reason,
+		})
+		return err
+	})
+}
+
+func parseID(s string) *pb.ID {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return &pb.ID{Kind: &pb.ID_Num{Num: n}}
+	}
+	return &pb.ID{Kind: &pb.ID_Str{Str: s}}
+}
+
+func formatID(id *pb.ID) string {
+	if v, ok := id.GetKind().(*pb.ID_Str); ok {
+		return strconv.Quote(v.Str)
+	}
+	return strconv.FormatInt(id.GetNum(), 10)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func since(t time.Time) string {
+	return time.Since(t).Truncate(time.Second).String()
+}
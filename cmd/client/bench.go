@@ -0,0 +1,459 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"os"
+	"os/signal"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/client"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/message"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gopkg.in/yaml.v3"
+)
+
+type benchCmd struct {
+	Streams     int           `short:"c" default:"1" help:"Number of concurrent streams, each with a session of its own"`
+	Concurrency int           `default:"1" help:"Maximum number of outstanding requests per stream"`
+	Rate        float64       `help:"Target rate of requests per second across all streams; 0 sends a request as soon as a previous one is answered"`
+	Duration    time.Duration `short:"d" default:"10s" help:"Duration of the run"`
+	Requests    int           `short:"n" help:"Stop after this many requests, if before the duration"`
+	Script      string        `type:"existingfile" help:"YAML file with the weighted mix of requests to send; only pings if unset"`
+	Timeout     time.Duration `default:"30s" help:"Timeout of each request"`
+	Output      string        `short:"o" enum:"text,json" default:"text" help:"Output format: text or json"`
+}
+
+// benchScript is the mix of requests of a run, e.g.
+//
+//	requests:
+//	  - method: tools/call
+//	    params: {name: hello, arguments: {name: Ann}}
+//	    weight: 8
+//	  - method: resources/read
+//	    params: {uri: file:///readme}
+//	    weight: 1
+//	  - method: ping
+type benchScript struct {
+	Requests []benchRequest `yaml:"requests"`
+}
+
+type benchRequest struct {
+	// Name labels the request in the report. It defaults to the method,
+	// followed by the tool name for tools/call.
+	Name   string         `yaml:"name"`
+	Method string         `yaml:"method"`
+	Params map[string]any `yaml:"params"`
+	// Weight is the share of the request in the mix, 1 by default
+	Weight *int `yaml:"weight"`
+}
+
+// errToolResult is recorded for a tools/call result with isError set
+var errToolResult = errors.New("tool error")
+
+func (c *benchCmd) Run(g *Globals) error {
+	if c.Streams < 1 || c.Concurrency < 1 {
+		return errors.New("--streams and --concurrency must be at least 1")
+	}
+	mix, err := c.mix()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sessions := make([]*mcpSession, c.Streams)
+	for i := range sessions {
+		// Without retries, so that every failure is reported
+		s, err := openSession(ctx, g, func(client.Notification) {}, nil)
+		if err != nil {
+			return fmt.Errorf("stream %d: %w", i+1, err)
+		}
+		defer s.close()
+		sessions[i] = s
+	}
+
+	// Ctrl-C ends the run early, and still prints the report
+	runCtx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+	runCtx, stopRun := context.WithTimeout(runCtx, c.Duration)
+	defer stopRun()
+
+	r := &benchRun{cmd: c, mix: mix, stats: map[string]*benchStats{}}
+	for _, req := range mix.requests {
+		r.stats[req.Name] = &benchStats{errors: map[string]int{}}
+	}
+	start := time.Now()
+	if c.Rate > 0 {
+		r.openLoop(ctx, runCtx, sessions)
+	} else {
+		r.closedLoop(ctx, runCtx, sessions)
+	}
+	r.wg.Wait()
+
+	report := r.report(time.Since(start))
+	if c.Output == "json" {
+		return printJSON(report)
+	}
+	return report.print()
+}
+
+// benchMix picks the requests of a run at random by their weight
+type benchMix struct {
+	requests []benchRequest
+	total    int
+}
+
+func (m *benchMix) pick() *benchRequest {
+	n := rand.N(m.total)
+	for i := range m.requests {
+		if n -= *m.requests[i].Weight; n < 0 {
+			return &m.requests[i]
+		}
+	}
+	return &m.requests[len(m.requests)-1]
+}
+
+func (c *benchCmd) mix() (*benchMix, error) {
+	script := benchScript{Requests: []benchRequest{{Method: "ping"}}}
+	if c.Script != "" {
+		b, err := os.ReadFile(c.Script)
+		if err != nil {
+			return nil, err
+		}
+		script = benchScript{}
+		if err := yaml.Unmarshal(b, &script); err != nil {
+			return nil, fmt.Errorf("%s: %w", c.Script, err)
+		}
+	}
+
+	m := &benchMix{}
+	for i, req := range script.Requests {
+		if req.Method == "" {
+			return nil, fmt.Errorf("%s: request %d has no method", c.Script, i+1)
+		}
+		if req.Weight == nil {
+			one := 1
+			req.Weight = &one
+		}
+		if *req.Weight < 0 {
+			return nil, fmt.Errorf("%s: request %d has a negative weight", c.Script, i+1)
+		}
+		if req.Name == "" {
+			req.Name = req.Method
+			if name, ok := req.Params["name"].(string); ok && req.Method == message.MethodToolsCall {
+				req.Name += " " + name
+			}
+		}
+		m.requests = append(m.requests, req)
+		m.total += *req.Weight
+	}
+	if m.total == 0 {
+		return nil, fmt.Errorf("%s: the requests have no weight", c.Script)
+	}
+	return m, nil
+}
+
+// benchRun is a run in progress
+type benchRun struct {
+	cmd  *benchCmd
+	mix  *benchMix
+	wg   sync.WaitGroup
+	sent atomic.Int64
+
+	mu      sync.Mutex
+	stats   map[string]*benchStats
+	skipped int
+}
+
+// benchStats are the results of the requests of one name
+type benchStats struct {
+	// latencies are those of the successful requests
+	latencies []time.Duration
+	errors    map[string]int
+}
+
+// take counts a request about to be sent, and reports false once the
+// request limit is reached
+func (r *benchRun) take() bool {
+	return r.cmd.Requests <= 0 || r.sent.Add(1) <= int64(r.cmd.Requests)
+}
+
+// closedLoop runs --concurrency workers per stream, which send a request as
+// soon as their previous one is answered
+func (r *benchRun) closedLoop(ctx, runCtx context.Context, sessions []*mcpSession) {
+	for _, s := range sessions {
+		for range r.cmd.Concurrency {
+			r.wg.Add(1)
+			go func() {
+				defer r.wg.Done()
+				for runCtx.Err() == nil && !isClosed(s) && r.take() {
+					r.call(ctx, s, r.mix.pick())
+				}
+			}()
+		}
+	}
+}
+
+// openLoop sends requests at the target rate, spread round-robin over the
+// streams. A request due while every stream has --concurrency outstanding is
+// skipped, and counted as such.
+func (r *benchRun) openLoop(ctx, runCtx context.Context, sessions []*mcpSession) {
+	slots := make([]chan struct{}, len(sessions))
+	for i := range slots {
+		slots[i] = make(chan struct{}, r.cmd.Concurrency)
+	}
+	interval := max(time.Duration(float64(time.Second)/r.cmd.Rate), time.Millisecond)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	start := time.Now()
+	scheduled, next := int64(0), 0
+	for {
+		select {
+		case <-runCtx.Done():
+			return
+		case <-ticker.C:
+		}
+		due := int64(time.Since(start).Seconds()*r.cmd.Rate) - scheduled
+		for ; due > 0; due-- {
+			scheduled++
+			if !r.take() {
+				return
+			}
+			i, ok := freeSlot(slots, sessions, next)
+			if !ok {
+				if !slices.ContainsFunc(sessions, func(s *mcpSession) bool { return !isClosed(s) }) {
+					return
+				}
+				r.mu.Lock()
+				r.skipped++
+				r.mu.Unlock()
+				continue
+			}
+			next = i + 1
+			r.wg.Add(1)
+			go func() {
+				defer func() {
+					<-slots[i]
+					r.wg.Done()
+				}()
+				r.call(ctx, sessions[i], r.mix.pick())
+			}()
+		}
+	}
+}
+
+// freeSlot takes a slot of the first open stream from start on which has
+// one, and returns its index
+func freeSlot(slots []chan struct{}, sessions []*mcpSession, start int) (int, bool) {
+	for j := range slots {
+		i := (start + j) % len(slots)
+		if isClosed(sessions[i]) {
+			continue
+		}
+		select {
+		case slots[i] <- struct{}{}:
+			return i, true
+		default:
+		}
+	}
+	return 0, false
+}
+
+func isClosed(s *mcpSession) bool {
+	select {
+	case <-s.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *benchRun) call(ctx context.Context, s *mcpSession, req *benchRequest) {
+	ctx, cancel := context.WithTimeout(ctx, r.cmd.Timeout)
+	defer cancel()
+	var result struct {
+		IsError bool `json:"isError"`
+	}
+	start := time.Now()
+	err := s.Call(ctx, req.Method, req.Params, &result)
+	d := time.Since(start)
+	if err == nil && result.IsError {
+		err = errToolResult
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st := r.stats[req.Name]
+	if err != nil {
+		st.errors[errorKind(err)]++
+		return
+	}
+	st.latencies = append(st.latencies, d)
+}
+
+// errorKind groups errors for the report: JSON-RPC errors by code and
+// message, gRPC errors by status code
+func errorKind(err error) string {
+	var re *client.Error
+	switch {
+	case errors.As(err, &re):
+		return fmt.Sprintf("JSON-RPC %d: %s", re.Code, re.Message)
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, errToolResult):
+		return errToolResult.Error()
+	}
+	if st, ok := status.FromError(err); ok && st.Code() != codes.Unknown {
+		return "gRPC " + st.Code().String()
+	}
+	return err.Error()
+}
+
+type benchReport struct {
+	Streams     int     `json:"streams"`
+	Concurrency int     `json:"concurrency"`
+	TargetRate  float64 `json:"targetRate,omitempty"`
+	Seconds     float64 `json:"seconds"`
+	Requests    int     `json:"requests"`
+	Errors      int     `json:"errors"`
+	// Skipped counts the requests of an open-loop run which were due
+	// while every stream was at its concurrency limit
+	Skipped    int            `json:"skipped"`
+	Throughput float64        `json:"throughput"`
+	Methods    []methodReport `json:"methods"`
+}
+
+type methodReport struct {
+	Name       string         `json:"name"`
+	Requests   int            `json:"requests"`
+	Errors     int            `json:"errors"`
+	Throughput float64        `json:"throughput"`
+	LatencyMs  latencyReport  `json:"latencyMs"`
+	ErrorKinds map[string]int `json:"errorKinds,omitempty"`
+}
+
+// latencyReport summarizes the latencies of successful requests
+type latencyReport struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P95  float64 `json:"p95"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+}
+
+func (r *benchRun) report(elapsed time.Duration) *benchReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rep := &benchReport{
+		Streams:     r.cmd.Streams,
+		Concurrency: r.cmd.Concurrency,
+		TargetRate:  r.cmd.Rate,
+		Seconds:     elapsed.Seconds(),
+		Skipped:     r.skipped,
+	}
+	for _, req := range r.mix.requests {
+		if slices.ContainsFunc(rep.Methods, func(m methodReport) bool { return m.Name == req.Name }) {
+			continue
+		}
+		st := r.stats[req.Name]
+		m := methodReport{Name: req.Name, LatencyMs: summarize(st.latencies)}
+		for _, n := range st.errors {
+			m.Errors += n
+		}
+		if m.Errors > 0 {
+			m.ErrorKinds = st.errors
+		}
+		m.Requests = len(st.latencies) + m.Errors
+		m.Throughput = float64(m.Requests) / elapsed.Seconds()
+		rep.Requests += m.Requests
+		rep.Errors += m.Errors
+		rep.Methods = append(rep.Methods, m)
+	}
+	rep.Throughput = float64(rep.Requests) / elapsed.Seconds()
+	return rep
+}
+
+func summarize(latencies []time.Duration) latencyReport {
+	if len(latencies) == 0 {
+		return latencyReport{}
+	}
+	sorted := slices.Clone(latencies)
+	slices.Sort(sorted)
+	var sum time.Duration
+	for _, d := range sorted {
+		sum += d
+	}
+	return latencyReport{
+		Min:  ms(sorted[0]),
+		Mean: ms(sum / time.Duration(len(sorted))),
+		P50:  ms(percentile(sorted, 50)),
+		P90:  ms(percentile(sorted, 90)),
+		P95:  ms(percentile(sorted, 95)),
+		P99:  ms(percentile(sorted, 99)),
+		Max:  ms(sorted[len(sorted)-1]),
+	}
+}
+
+// percentile returns the nearest-rank percentile of sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
+
+func ms(d time.Duration) float64 {
+	return math.Round(float64(d.Microseconds())) / 1000
+}
+
+func (rep *benchReport) print() error {
+	streams := "streams"
+	if rep.Streams == 1 {
+		streams = "stream"
+	}
+	fmt.Printf("%d requests on %d %s in %.1fs: %.1f requests/s, %d errors", rep.Requests, rep.Streams, streams, rep.Seconds, rep.Throughput, rep.Errors)
+	if rep.Skipped > 0 {
+		fmt.Printf(", %d skipped as all streams were busy", rep.Skipped)
+	}
+	fmt.Println()
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "NAME\tREQUESTS\tERRORS\tREQ/S\tMIN\tMEAN\tP50\tP90\tP95\tP99\tMAX\t")
+	for _, m := range rep.Methods {
+		l := m.LatencyMs
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.1f\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t\n",
+			m.Name, m.Requests, m.Errors, m.Throughput, l.Min, l.Mean, l.P50, l.P90, l.P95, l.P99, l.Max)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Println("Latencies of successful requests in ms")
+
+	for _, m := range rep.Methods {
+		if len(m.ErrorKinds) == 0 {
+			continue
+		}
+		kinds := make([]string, 0, len(m.ErrorKinds))
+		for k := range m.ErrorKinds {
+			kinds = append(kinds, k)
+		}
+		sort.Slice(kinds, func(i, j int) bool { return m.ErrorKinds[kinds[i]] > m.ErrorKinds[kinds[j]] })
+		fmt.Printf("\nErrors of %s:\n", m.Name)
+		for _, k := range kinds {
+			fmt.Printf("  %6d  %s\n", m.ErrorKinds[k], k)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	hundred := make([]time.Duration, 100)
+	for i := range hundred {
+		hundred[i] = time.Duration(i+1) * time.Millisecond
+	}
+	tests := []struct {
+		name   string
+		sorted []time.Duration
+		p      float64
+		want   time.Duration
+	}{
+		{"one p0", []time.Duration{time.Second}, 0, time.Second},
+		{"one p50", []time.Duration{time.Second}, 50, time.Second},
+		{"one p100", []time.Duration{time.Second}, 100, time.Second},
+		{"hundred p0", hundred, 0, 1 * time.Millisecond},
+		{"hundred p1", hundred, 1, 1 * time.Millisecond},
+		{"hundred p50", hundred, 50, 50 * time.Millisecond},
+		{"hundred p90", hundred, 90, 90 * time.Millisecond},
+		{"hundred p99", hundred, 99, 99 * time.Millisecond},
+		{"hundred p99.5", hundred, 99.5, 100 * time.Millisecond},
+		{"hundred p100", hundred, 100, 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := percentile(tt.sorted, tt.p); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	if got := summarize(nil); got != (latencyReport{}) {
+		t.Errorf("expected an empty report without latencies, got %+v", got)
+	}
+
+	latencies := []time.Duration{4 * time.Millisecond, 1 * time.Millisecond, 1500 * time.Microsecond, 2500 * time.Microsecond}
+	want := latencyReport{Min: 1, Mean: 2.25, P50: 1.5, P90: 4, P95: 4, P99: 4, Max: 4}
+	if got := summarize(slices.Clone(latencies)); got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	in := slices.Clone(latencies)
+	summarize(in)
+	if !slices.Equal(in, latencies) {
+		t.Errorf("expected the latencies to be left unsorted, got %v", in)
+	}
+}
+
+func TestBenchCmd_Mix(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		names  []string
+		total  int
+		err    string
+	}{
+		{
+			name:  "no script",
+			names: []string{"ping"},
+			total: 1,
+		},
+		{
+			name: "weights and names",
+			script: `requests:
+  - method: tools/call
+    params: {name: hello}
+    weight: 8
+  - method: resources/read
+    name: readme
+    weight: 0
+  - method: ping`,
+			names: []string{"tools/call hello", "readme", "ping"},
+			total: 9,
+		},
+		{
+			name:   "no method",
+			script: "requests:\n  - weight: 1\n",
+			err:    "request 1 has no method",
+		},
+		{
+			name:   "negative weight",
+			script: "requests:\n  - method: ping\n  - method: tools/list\n    weight: -1\n",
+			err:    "request 2 has a negative weight",
+		},
+		{
+			name:   "no weight",
+			script: "requests:\n  - method: ping\n    weight: 0\n",
+			err:    "the requests have no weight",
+		},
+		{
+			name:   "no requests",
+			script: "requests: []\n",
+			err:    "the requests have no weight",
+		},
+		{
+			name:   "invalid YAML",
+			script: "requests: {",
+			err:    "yaml",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &benchCmd{}
+			if tt.script != "" {
+				c.Script = filepath.Join(t.TempDir(), "bench.yaml")
+				if err := os.WriteFile(c.Script, []byte(tt.script), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			m, err := c.mix()
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected an error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, req := range m.requests {
+				names = append(names, req.Name)
+			}
+			if !slices.Equal(names, tt.names) || m.total != tt.total {
+				t.Errorf("expected %v with a total weight of %d, got %v with %d", tt.names, tt.total, names, m.total)
+			}
+		})
+	}
+}
+
+func TestBenchMix_Pick(t *testing.T) {
+	three, zero, one := 3, 0, 1
+	m := &benchMix{
+		requests: []benchRequest{{Name: "a", Weight: &three}, {Name: "b", Weight: &zero}, {Name: "c", Weight: &one}},
+		total:    4,
+	}
+	picks := map[string]int{}
+	for range 4000 {
+		picks[m.pick().Name]++
+	}
+	if picks["b"] != 0 {
+		t.Errorf("expected a request of no weight never to be picked, got %d picks", picks["b"])
+	}
+	if a := picks["a"]; a < 2700 || a > 3300 {
+		t.Errorf("expected about 3000 of 4000 picks by weight, got %d", a)
+	}
+}
@@ -0,0 +1,548 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/client"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/codec"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/message"
+	pb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/jsonrpc"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/session"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/transcript"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// errCodeNoResponse answers the requests the client gave up on, as they were
+// outstanding when the stream broke or when draining after stdin closed timed
+// out. The server may or may not have handled them.
+const errCodeNoResponse = -32004
+
+// reinitializeID is the request ID of an initialize request replayed on a new
+// stream. Its response is consumed by the client. It is a number, as servers
+// built on metoro-io/mcp-golang reject string IDs, and one no client counts
+// down to, the lowest integer a JSON number holds exactly.
+const reinitializeID = -(1<<53 - 1)
+
+// expiredFor is how long the ID of a timed out request is remembered, to drop
+// its late response. A response later than that is passed on to the IDE.
+const expiredFor = 10 * time.Minute
+
+// bridge relays the messages read from stdin to a Transport stream and the
+// messages received on it to stdout. When the stream breaks it is reopened,
+// and the session is initialized again with the client's cached handshake.
+// The idempotent requests outstanding on the broken stream are sent again on
+// the new one, as the retry policy allows.
+type bridge struct {
+	client    pb.JSONRPCServiceClient
+	recorder  *transcript.Recorder
+	reconnect bool
+	backoff   backoff
+	retry     *client.RetryPolicy
+	// requestTimeout is how long a request may go unanswered, or 0
+	requestTimeout time.Duration
+
+	mu      sync.Mutex
+	conn    *connection
+	ready   chan struct{} // closed once conn is set
+	pending map[string]*pendingRequest
+	// expired are the requests which timed out, by the time they did, whose
+	// late responses are dropped as the IDE has been answered already
+	expired map[string]time.Time
+	// serverRequests maps the server-initiated requests awaiting the IDE's
+	// response to the stream they arrived on
+	serverRequests map[string]*connection
+	// requests and lost count the requests sent, and those answered by the
+	// client instead of the server
+	requests, lost int
+	// eof is closed once stdin is exhausted and the stream half-closed
+	eof chan struct{}
+	// initialize and initialized are the handshake of the session, replayed
+	// on every new stream
+	initialize  *pb.GenericJSONRPCMessage
+	initialized *pb.GenericJSONRPCMessage
+
+	// out is where the messages for the IDE are written, stdout
+	out   io.Writer
+	outMu sync.Mutex
+}
+
+// connection is one Transport stream, which is one server session
+type connection struct {
+	stream    pb.JSONRPCService_TransportClient
+	cancel    context.CancelFunc
+	sessionID string
+	sendMu    sync.Mutex
+}
+
+// send sends a message on the stream, which stdin and the request timers
+// share
+func (c *connection) send(msg *pb.GenericJSONRPCMessage) error {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	return c.stream.Send(msg)
+}
+
+// pendingRequest is a request sent to the server and awaiting its response.
+// A request awaiting a retry has no connection until the next stream opens.
+type pendingRequest struct {
+	request  *pb.GenericJSONRPCMessage
+	conn     *connection
+	timer    *time.Timer
+	attempts int
+}
+
+func newBridge(client pb.JSONRPCServiceClient, recorder *transcript.Recorder) *bridge {
+	return &bridge{
+		client:         client,
+		recorder:       recorder,
+		ready:          make(chan struct{}),
+		pending:        map[string]*pendingRequest{},
+		expired:        map[string]time.Time{},
+		serverRequests: map[string]*connection{},
+		eof:            make(chan struct{}),
+		out:            os.Stdout,
+	}
+}
+
+// current waits until a stream is open and returns it, or nil once ctx ends
+func (b *bridge) current(ctx context.Context) *connection {
+	for {
+		b.mu.Lock()
+		conn, ready := b.conn, b.ready
+		b.mu.Unlock()
+		if conn != nil {
+			return conn
+		}
+		select {
+		case <-ready:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// send sends a message read from stdin. While the stream is reconnecting it
+// waits for the new one. A request which cannot be sent is answered with an
+// error. Responses to server-initiated requests go to the stream the request
+// arrived on, and are dropped if it has broken since.
+func (b *bridge) send(ctx context.Context, msg *pb.GenericJSONRPCMessage) {
+	if message.IsResponse(msg) {
+		b.mu.Lock()
+		conn := b.serverRequests[formatID(msg.TypedId)]
+		delete(b.serverRequests, formatID(msg.TypedId))
+		current := b.conn
+		b.mu.Unlock()
+		if conn == nil || conn != current {
+			fmt.Fprintf(os.Stderr, "Dropping response %s: no such request on the current stream\n", formatID(msg.TypedId))
+			return
+		}
+		b.recorder.Record(conn.sessionID, transcript.DirectionToServer, msg)
+		if err := conn.send(msg); err != nil {
+			fmt.Fprintf(os.Stderr, "Send error: %v\n", err)
+		}
+		return
+	}
+
+	conn := b.current(ctx)
+	if conn == nil {
+		return
+	}
+
+	isRequest := message.IsRequest(msg)
+	key := formatID(msg.TypedId)
+	if isRequest {
+		b.mu.Lock()
+		req := &pendingRequest{request: msg, conn: conn, attempts: 1}
+		if b.requestTimeout > 0 {
+			req.timer = time.AfterFunc(b.requestTimeout, func() { b.expire(key) })
+		}
+		b.pending[key] = req
+		delete(b.expired, key)
+		b.requests++
+		b.mu.Unlock()
+		if b.retry != nil {
+			b.retry.Sent()
+		}
+	}
+	if msg.Method == "notifications/initialized" {
+		b.mu.Lock()
+		b.initialized = msg
+		b.mu.Unlock()
+	}
+
+	b.recorder.Record(conn.sessionID, transcript.DirectionToServer, msg)
+	if err := conn.send(msg); err != nil {
+		fmt.Fprintf(os.Stderr, "Send error: %v\n", err)
+		if isRequest {
+			b.fail(key, connectionLost(err))
+		}
+	}
+}
+
+// closeSend half-closes the stream once stdin is exhausted, so that the
+// server ends the session after answering the outstanding requests
+func (b *bridge) closeSend() {
+	b.mu.Lock()
+	close(b.eof)
+	conn := b.conn
+	b.mu.Unlock()
+	if conn != nil {
+		conn.stream.CloseSend()
+	}
+}
+
+// abandon answers every outstanding request with an error
+func (b *bridge) abandon(reason string) {
+	b.mu.Lock()
+	var keys []string
+	for key := range b.pending {
+		keys = append(keys, key)
+	}
+	b.mu.Unlock()
+
+	for _, key := range keys {
+		b.fail(key, reason)
+	}
+}
+
+// unanswered returns an error if any request was not answered by the server
+func (b *bridge) unanswered() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.lost > 0 {
+		return fmt.Errorf("%d of %d requests were not answered by the server", b.lost, b.requests)
+	}
+	return nil
+}
+
+func (b *bridge) closed() bool {
+	select {
+	case <-b.eof:
+		return true
+	default:
+		return false
+	}
+}
+
+// receive writes a message received from the server to stdout: responses to
+// the IDE's requests, and the server's own notifications and requests
+func (b *bridge) receive(conn *connection, msg *pb.GenericJSONRPCMessage) {
+	b.recorder.Record(conn.sessionID, transcript.DirectionToClient, msg)
+	switch {
+	case message.IsRequest(msg):
+		b.mu.Lock()
+		b.serverRequests[formatID(msg.TypedId)] = conn
+		b.mu.Unlock()
+	case message.IsResponse(msg):
+		key := formatID(msg.TypedId)
+		b.mu.Lock()
+		req := b.pending[key]
+		delete(b.pending, key)
+		if req != nil {
+			req.stop()
+			if req.request.Method == message.MethodInitialize && msg.Error == nil {
+				b.initialize = req.request
+			}
+		}
+		_, late := b.expired[key]
+		delete(b.expired, key)
+		b.mu.Unlock()
+		if late {
+			fmt.Fprintf(os.Stderr, "Dropping response %s: it arrived after the request timed out\n", key)
+			return
+		}
+	}
+	b.write(msg)
+}
+
+func (r *pendingRequest) stop() {
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+}
+
+// fail answers an outstanding request with an error, unless its response has
+// arrived already. It returns the request if it was still outstanding.
+func (b *bridge) fail(key string, reason string) *pendingRequest {
+	b.mu.Lock()
+	req := b.pending[key]
+	delete(b.pending, key)
+	if req != nil {
+		req.stop()
+		b.lost++
+	}
+	b.mu.Unlock()
+	if req == nil {
+		return nil
+	}
+	b.write(message.NewError(req.request.TypedId, errCodeNoResponse, reason, nil))
+	return req
+}
+
+// expire answers a request which got no response within the request timeout,
+// and tells the server to stop working on it
+func (b *bridge) expire(key string) {
+	reason := fmt.Sprintf("no response within the request timeout of %s", b.requestTimeout)
+	req := b.fail(key, reason)
+	if req == nil {
+		return
+	}
+	now := time.Now()
+	b.mu.Lock()
+	for k, at := range b.expired {
+		if now.Sub(at) > expiredFor {
+			delete(b.expired, k)
+		}
+	}
+	b.expired[key] = now
+	b.mu.Unlock()
+	if req.conn == nil {
+		// Awaiting a retry, so no server has it
+		return
+	}
+
+	cancelled := message.NewCancelled(req.request.TypedId, reason)
+	b.recorder.Record(req.conn.sessionID, transcript.DirectionToServer, cancelled)
+	if err := req.conn.send(cancelled); err != nil {
+		fmt.Fprintf(os.Stderr, "Send error: %v\n", err)
+	}
+}
+
+func connectionLost(err error) string {
+	return fmt.Sprintf("connection to the server was lost: %v", status.Convert(err).Message())
+}
+
+// disconnected fails the requests outstanding on a broken stream, but keeps
+// those to be retried on the next one
+func (b *bridge) disconnected(conn *connection, err error) {
+	conn.cancel()
+	retry := b.retry != nil && b.reconnect && client.Transient(err) && !b.closed()
+	b.mu.Lock()
+	if b.conn == conn {
+		b.conn = nil
+		b.ready = make(chan struct{})
+	}
+	var keys []string
+	retries := 0
+	for key, req := range b.pending {
+		if req.conn != conn {
+			continue
+		}
+		if retry && b.retry.Idempotent(req.request.Method, message.ToolName(req.request)) && b.retry.Retry(req.attempts) {
+			req.conn = nil
+			req.attempts++
+			retries++
+			continue
+		}
+		keys = append(keys, key)
+	}
+	for key, c := range b.serverRequests {
+		if c == conn {
+			delete(b.serverRequests, key)
+		}
+	}
+	b.mu.Unlock()
+
+	if retries > 0 {
+		fmt.Fprintf(os.Stderr, "Retrying %d idempotent requests on the next stream\n", retries)
+	}
+	for _, key := range keys {
+		b.fail(key, connectionLost(err))
+	}
+}
+
+// dropRetries fails the requests awaiting a retry once no stream follows
+func (b *bridge) dropRetries(err error) {
+	b.mu.Lock()
+	var keys []string
+	for key, req := range b.pending {
+		if req.conn == nil {
+			keys = append(keys, key)
+		}
+	}
+	b.mu.Unlock()
+
+	for _, key := range keys {
+		b.fail(key, connectionLost(err))
+	}
+}
+
+func (b *bridge) write(msg *pb.GenericJSONRPCMessage) {
+	out, err := codec.Encode(msg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Marshal error: %v\n", err)
+		return
+	}
+	b.outMu.Lock()
+	defer b.outMu.Unlock()
+	fmt.Fprintln(b.out, string(out))
+}
+
+// run opens streams until one ends cleanly, the context ends, or a stream
+// fails with an error which reconnecting cannot fix. Once stdin is closed
+// there is nothing left to reconnect for.
+func (b *bridge) run(ctx context.Context) error {
+	var err error
+	defer func() { b.dropRetries(err) }()
+	for attempt := 0; ; attempt++ {
+		var conn *connection
+		conn, err = b.connect(ctx)
+		if err == nil {
+			attempt = 0
+			err = b.serve(conn)
+			if err == nil {
+				// Any request the server ended the session without
+				// answering is lost
+				b.disconnected(conn, io.ErrUnexpectedEOF)
+				return nil
+			}
+			b.disconnected(conn, err)
+		}
+		if ctx.Err() != nil || b.closed() {
+			return nil
+		}
+		if !b.reconnect || !retryable(err) {
+			return err
+		}
+
+		delay := b.backoff.delay(attempt)
+		fmt.Fprintf(os.Stderr, "Stream error: %v; reconnecting in %s\n", err, delay.Round(time.Millisecond))
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil
+		case <-b.eof:
+			return nil
+		}
+	}
+}
+
+// connect opens a stream and replays the session's handshake on it before
+// handing it to stdin
+func (b *bridge) connect(ctx context.Context) (*connection, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	stream, err := b.client.Transport(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	conn := &connection{stream: stream, cancel: cancel, sessionID: session.NewID()}
+
+	b.mu.Lock()
+	initialize, initialized := b.initialize, b.initialized
+	b.mu.Unlock()
+	if initialize != nil {
+		if err := b.reinitialize(conn, initialize, initialized); err != nil {
+			cancel()
+			return nil, err
+		}
+		fmt.Fprintf(os.Stderr, "Reconnected and initialized a new session on %s\n", backend(stream.Context()))
+	}
+
+	b.mu.Lock()
+	b.conn = conn
+	close(b.ready)
+	closed := b.closed()
+	retries := map[string]*pendingRequest{}
+	for key, req := range b.pending {
+		if req.conn == nil {
+			req.conn = conn
+			retries[key] = req
+		}
+	}
+	b.mu.Unlock()
+	for key, req := range retries {
+		b.recorder.Record(conn.sessionID, transcript.DirectionToServer, req.request)
+		if err := conn.send(req.request); err != nil {
+			b.fail(key, connectionLost(err))
+		}
+	}
+	if closed {
+		conn.stream.CloseSend()
+	}
+	return conn, nil
+}
+
+func (b *bridge) reinitialize(conn *connection, initialize, initialized *pb.GenericJSONRPCMessage) error {
+	req := proto.Clone(initialize).(*pb.GenericJSONRPCMessage)
+	req.TypedId = &pb.ID{Kind: &pb.ID_Num{Num: reinitializeID}}
+	b.recorder.Record(conn.sessionID, transcript.DirectionToServer, req)
+	if err := conn.send(req); err != nil {
+		return err
+	}
+	for {
+		msg, err := conn.stream.Recv()
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		if msg.GetTypedId().GetNum() != reinitializeID || msg.Method != "" {
+			b.receive(conn, msg)
+			continue
+		}
+		b.recorder.Record(conn.sessionID, transcript.DirectionToClient, msg)
+		if e := msg.GetError(); e != nil {
+			return status.Errorf(codes.FailedPrecondition, "server rejected the replayed initialize: %s", e.Message)
+		}
+		break
+	}
+	if initialized != nil {
+		b.recorder.Record(conn.sessionID, transcript.DirectionToServer, initialized)
+		return conn.send(initialized)
+	}
+	return nil
+}
+
+// serve prints the messages of a stream until it ends. It returns nil if the
+// server closed the stream.
+func (b *bridge) serve(conn *connection) error {
+	for {
+		msg, err := conn.stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		b.receive(conn, msg)
+	}
+}
+
+// retryable reports whether reopening the stream may succeed after err.
+// Errors about the caller itself, or a session terminated by an
+// administrator, are not retried.
+func retryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unauthenticated, codes.PermissionDenied, codes.InvalidArgument,
+		codes.Unimplemented, codes.FailedPrecondition, codes.Aborted:
+		return false
+	}
+	return true
+}
+
+// backoff computes exponentially growing reconnect delays with jitter, so
+// that clients of a restarted server do not reconnect in lockstep
+type backoff struct {
+	base, max time.Duration
+}
+
+// delay returns the wait before the given attempt, counted from 0: a random
+// duration between half and all of base*2^attempt, capped at max
+func (b backoff) delay(attempt int) time.Duration {
+	d := b.base
+	for i := 0; i < attempt && d < b.max; i++ {
+		d *= 2
+	}
+	d = min(d, b.max)
+	return d/2 + rand.N(d/2+1)
+}
@@ -0,0 +1,426 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/codec"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/message"
+	pb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/jsonrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+// fakeServer answers initialize, and calls of tools by their name: "echo" is
+// answered with its name, "held" only once the client half-closes the stream,
+// and "slow" only once it is cancelled, leaving the stream open until then. "ask" sends a notification and a
+// request of the server's own, and is answered once that request is. "break"
+// and "deny" fail the stream as Unavailable and PermissionDenied. It keeps the
+// messages of every stream.
+type fakeServer struct {
+	pb.UnimplementedJSONRPCServiceServer
+
+	mu      sync.Mutex
+	streams [][]*pb.GenericJSONRPCMessage
+}
+
+// received returns the messages received on the i-th stream opened
+func (s *fakeServer) received(i int) []*pb.GenericJSONRPCMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i >= len(s.streams) {
+		return nil
+	}
+	return append([]*pb.GenericJSONRPCMessage(nil), s.streams[i]...)
+}
+
+// opened returns the number of streams opened
+func (s *fakeServer) opened() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.streams)
+}
+
+func (s *fakeServer) Transport(stream pb.JSONRPCService_TransportServer) error {
+	s.mu.Lock()
+	i := len(s.streams)
+	s.streams = append(s.streams, nil)
+	s.mu.Unlock()
+
+	var held []*pb.GenericJSONRPCMessage
+	slow := map[string]*pb.GenericJSONRPCMessage{}
+	asked := map[string]*pb.GenericJSONRPCMessage{}
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			for _, req := range held {
+				if err := stream.Send(result(req, "held")); err != nil {
+					return err
+				}
+			}
+			if len(slow) > 0 {
+				<-stream.Context().Done()
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		s.mu.Lock()
+		s.streams[i] = append(s.streams[i], msg)
+		s.mu.Unlock()
+
+		var reply *pb.GenericJSONRPCMessage
+		switch {
+		case msg.Method == message.MethodInitialize:
+			reply = decode(fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":{"protocolVersion":"2025-03-26","capabilities":{},"serverInfo":{"name":"fake","version":"1.0.0"}}}`, formatID(msg.TypedId)))
+		case msg.Method == message.MethodCancelled:
+			key := message.IDKey(message.CancelledID(msg))
+			if req := slow[key]; req != nil {
+				delete(slow, key)
+				reply = result(req, "late")
+			}
+		case message.IsResponse(msg):
+			key := message.IDKey(msg.TypedId)
+			if req := asked[key]; req != nil {
+				delete(asked, key)
+				reply = result(req, "answered")
+			}
+		case !message.IsRequest(msg):
+		case message.ToolName(msg) == "held":
+			held = append(held, msg)
+		case message.ToolName(msg) == "slow":
+			slow[message.IDKey(msg.TypedId)] = msg
+		case message.ToolName(msg) == "ask":
+			ask := decode(fmt.Sprintf(`{"jsonrpc":"2.0","id":"roots-%s","method":"roots/list"}`, formatID(msg.TypedId)))
+			asked[message.IDKey(ask.TypedId)] = msg
+			if err := stream.Send(decode(`{"jsonrpc":"2.0","method":"notifications/message","params":{"level":"info","data":"asking"}}`)); err != nil {
+				return err
+			}
+			reply = ask
+		case message.ToolName(msg) == "break":
+			return status.Error(codes.Unavailable, "replica going away")
+		case message.ToolName(msg) == "deny":
+			return status.Error(codes.PermissionDenied, "not allowed")
+		default:
+			reply = result(msg, message.ToolName(msg))
+		}
+		if reply != nil {
+			if err := stream.Send(reply); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// decode decodes a message of the tests, which is valid
+func decode(s string) *pb.GenericJSONRPCMessage {
+	msg, err := codec.Decode([]byte(s))
+	if err != nil {
+		panic(err)
+	}
+	return msg
+}
+
+// call returns a call of a tool
+func call(id int, tool string) *pb.GenericJSONRPCMessage {
+	return decode(fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"tools/call","params":{"name":%q}}`, id, tool))
+}
+
+// result returns the result of a tool call with a single text
+func result(req *pb.GenericJSONRPCMessage, text string) *pb.GenericJSONRPCMessage {
+	return decode(fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":{"content":[{"type":"text","text":%q}]}}`, formatID(req.TypedId), text))
+}
+
+// text returns the text of a tool call result
+func text(msg *pb.GenericJSONRPCMessage) string {
+	content := msg.GetResult().GetFields()["content"].GetListValue().GetValues()
+	if len(content) == 0 {
+		return ""
+	}
+	return content[0].GetStructValue().GetFields()["text"].GetStringValue()
+}
+
+// listen serves srv over an in-memory listener
+func listen(t *testing.T, srv *fakeServer) *bufconn.Listener {
+	lis := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer()
+	pb.RegisterJSONRPCServiceServer(gs, srv)
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+	return lis
+}
+
+// output collects the messages a bridge writes for the IDE
+type output chan *pb.GenericJSONRPCMessage
+
+func (o output) Write(p []byte) (int, error) {
+	msg, err := codec.Decode(bytes.TrimSpace(p))
+	if err != nil {
+		return 0, err
+	}
+	o <- msg
+	return len(p), nil
+}
+
+// next returns the next message written
+func (o output) next(t *testing.T) *pb.GenericJSONRPCMessage {
+	t.Helper()
+	select {
+	case msg := <-o:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message was written")
+		return nil
+	}
+}
+
+// newTestBridge returns a bridge to srv, and what it writes
+func newTestBridge(t *testing.T, srv *fakeServer) (*bridge, output) {
+	lis := listen(t, srv)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	out := make(output, 16)
+	b := newBridge(pb.NewJSONRPCServiceClient(conn), nil)
+	b.out = out
+	return b, out
+}
+
+// runBridge runs b until the test ends, and returns the result of run
+func runBridge(t *testing.T, b *bridge) <-chan error {
+	errc := make(chan error, 1)
+	go func() { errc <- b.run(t.Context()) }()
+	return errc
+}
+
+// wait returns the result of run
+func wait(t *testing.T, errc <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-errc:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("the bridge did not stop")
+		return nil
+	}
+}
+
+// expectNoResponse fails the test unless msg is the error of a request the
+// client gave up on
+func expectNoResponse(t *testing.T, msg *pb.GenericJSONRPCMessage, id int64) {
+	t.Helper()
+	if msg.GetTypedId().GetNum() != id || msg.GetError().GetCode() != errCodeNoResponse {
+		t.Fatalf("expected a %d error for request %d, got %v", errCodeNoResponse, id, msg)
+	}
+}
+
+func TestBridge_ReconnectsAndReplaysTheHandshake(t *testing.T) {
+	srv := &fakeServer{}
+	b, out := newTestBridge(t, srv)
+	b.reconnect = true
+	b.backoff = backoff{base: time.Millisecond, max: 10 * time.Millisecond}
+	errc := runBridge(t, b)
+
+	b.send(t.Context(), decode(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","clientInfo":{"name":"ide"}}}`))
+	if msg := out.next(t); msg.GetTypedId().GetNum() != 1 || msg.Result == nil {
+		t.Fatalf("expected the initialize result, got %v", msg)
+	}
+	b.send(t.Context(), decode(`{"jsonrpc":"2.0","method":"notifications/initialized"}`))
+
+	b.send(t.Context(), call(2, "break"))
+	expectNoResponse(t, out.next(t), 2)
+
+	// Sent once the new stream is initialized, whose initialize result is
+	// not for the IDE
+	b.send(t.Context(), call(3, "echo"))
+	if msg := out.next(t); msg.GetTypedId().GetNum() != 3 || text(msg) != "echo" {
+		t.Fatalf("expected the echo result, got %v", msg)
+	}
+
+	first, second := srv.received(0), srv.received(1)
+	if len(second) != 3 {
+		t.Fatalf("expected the handshake and the call on the new stream, got %v", second)
+	}
+	if second[0].Method != message.MethodInitialize || second[0].GetTypedId().GetNum() != reinitializeID || !proto.Equal(second[0].Params, first[0].Params) {
+		t.Errorf("expected the initialize request to be replayed, got %v", second[0])
+	}
+	if second[1].Method != "notifications/initialized" {
+		t.Errorf("expected the initialized notification to be replayed, got %v", second[1])
+	}
+
+	b.closeSend()
+	if err := wait(t, errc); err != nil {
+		t.Errorf("expected the bridge to stop cleanly, got %v", err)
+	}
+	if n := srv.opened(); n != 2 {
+		t.Errorf("expected 2 streams, got %d", n)
+	}
+}
+
+func TestBridge_StopsOnErrorsReconnectingCannotFix(t *testing.T) {
+	srv := &fakeServer{}
+	b, out := newTestBridge(t, srv)
+	b.reconnect = true
+	b.backoff = backoff{base: time.Millisecond, max: 10 * time.Millisecond}
+	errc := runBridge(t, b)
+
+	b.send(t.Context(), call(1, "deny"))
+	expectNoResponse(t, out.next(t), 1)
+	if err := wait(t, errc); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("expected the PermissionDenied error, got %v", err)
+	}
+	if n := srv.opened(); n != 1 {
+		t.Errorf("expected no new stream, got %d streams", n)
+	}
+}
+
+func TestBridge_DrainsOnceStdinCloses(t *testing.T) {
+	srv := &fakeServer{}
+	b, out := newTestBridge(t, srv)
+	errc := runBridge(t, b)
+
+	b.send(t.Context(), call(1, "held"))
+	b.closeSend()
+	if msg := out.next(t); msg.GetTypedId().GetNum() != 1 || text(msg) != "held" {
+		t.Fatalf("expected the held result, got %v", msg)
+	}
+	if err := wait(t, errc); err != nil {
+		t.Errorf("expected the bridge to stop cleanly, got %v", err)
+	}
+	if err := b.unanswered(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBridge_AbandonsRequestsAfterTheDrainTimeout(t *testing.T) {
+	srv := &fakeServer{}
+	b, out := newTestBridge(t, srv)
+	runBridge(t, b)
+
+	b.send(t.Context(), call(1, "slow"))
+	b.send(t.Context(), call(2, "held"))
+	b.closeSend()
+	if msg := out.next(t); msg.GetTypedId().GetNum() != 2 || text(msg) != "held" {
+		t.Fatalf("expected the held result, got %v", msg)
+	}
+
+	b.abandon("no response within 1s after stdin was closed")
+	msg := out.next(t)
+	expectNoResponse(t, msg, 1)
+	if got := msg.GetError().GetMessage(); got != "no response within 1s after stdin was closed" {
+		t.Errorf("expected the drain timeout as the reason, got %q", got)
+	}
+	if err := b.unanswered(); err == nil || err.Error() != "1 of 2 requests were not answered by the server" {
+		t.Errorf("expected 1 of 2 requests to be unanswered, got %v", err)
+	}
+}
+
+func TestBridge_ForwardsServerMessages(t *testing.T) {
+	srv := &fakeServer{}
+	b, out := newTestBridge(t, srv)
+	runBridge(t, b)
+
+	// Dropped, as the server sent no such request
+	b.send(t.Context(), decode(`{"jsonrpc":"2.0","id":"unknown","result":{}}`))
+
+	b.send(t.Context(), call(1, "ask"))
+	if msg := out.next(t); msg.Method != "notifications/message" {
+		t.Fatalf("expected the notification, got %v", msg)
+	}
+	ask := out.next(t)
+	if ask.Method != "roots/list" || ask.GetTypedId().GetStr() != "roots-1" {
+		t.Fatalf("expected the server's request, got %v", ask)
+	}
+	b.send(t.Context(), decode(`{"jsonrpc":"2.0","id":"roots-1","result":{"roots":[]}}`))
+	if msg := out.next(t); msg.GetTypedId().GetNum() != 1 || text(msg) != "answered" {
+		t.Fatalf("expected the result once the server's request was answered, got %v", msg)
+	}
+
+	for _, msg := range srv.received(0) {
+		if msg.GetTypedId().GetStr() == "unknown" {
+			t.Errorf("expected the response to an unknown request to be dropped")
+		}
+	}
+}
+
+func TestBridge_TimesOutRequests(t *testing.T) {
+	srv := &fakeServer{}
+	b, out := newTestBridge(t, srv)
+	b.requestTimeout = 50 * time.Millisecond
+	b.expired["0"] = time.Now().Add(-expiredFor - time.Second)
+	runBridge(t, b)
+
+	b.send(t.Context(), call(1, "slow"))
+	msg := out.next(t)
+	expectNoResponse(t, msg, 1)
+	if got := msg.GetError().GetMessage(); got != "no response within the request timeout of 50ms" {
+		t.Errorf("expected the request timeout as the reason, got %q", got)
+	}
+
+	// The server is told to stop, and its late result dropped
+	deadline := time.Now().Add(5 * time.Second)
+	for !cancelled(srv.received(0), 1) {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the request to be cancelled on the server")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	b.send(t.Context(), call(2, "echo"))
+	if msg := out.next(t); msg.GetTypedId().GetNum() != 2 {
+		t.Fatalf("expected the late result to be dropped, got %v", msg)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.expired) != 0 {
+		t.Errorf("expected the expired requests to be forgotten, got %v", b.expired)
+	}
+}
+
+// cancelled reports whether msgs cancel the request with the given ID
+func cancelled(msgs []*pb.GenericJSONRPCMessage, id int64) bool {
+	for _, msg := range msgs {
+		if msg.Method == message.MethodCancelled && message.CancelledID(msg).GetNum() == id {
+			return true
+		}
+	}
+	return false
+}
+
+func TestBackoff_Delay(t *testing.T) {
+	b := backoff{base: 100 * time.Millisecond, max: time.Second}
+	tests := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{0, 50 * time.Millisecond, 100 * time.Millisecond},
+		{1, 100 * time.Millisecond, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond, 800 * time.Millisecond},
+		{4, 500 * time.Millisecond, time.Second},
+		{100, 500 * time.Millisecond, time.Second},
+	}
+	for _, tt := range tests {
+		for range 100 {
+			if d := b.delay(tt.attempt); d < tt.min || d > tt.max {
+				t.Errorf("attempt %d: expected a delay in [%s, %s], got %s", tt.attempt, tt.min, tt.max, d)
+				break
+			}
+		}
+	}
+}
@@ -2,30 +2,84 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
-	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/alecthomas/kong"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/codec"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/message"
 	pb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/jsonrpc"
-	grpc "google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/tracing"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/transcript"
+	"google.golang.org/grpc/metadata"
 )
 
+// Globals are the flags of every command. Any flag, of these or of the
+// commands, can be given a default by a profile of the config file.
+type Globals struct {
+	Config  string `default:"~/.config/mcp-grpc/config.yaml" env:"MCP_GRPC_CONFIG" type:"path" help:"Config file with the connection profiles"`
+	Profile string `env:"MCP_GRPC_PROFILE" help:"Profile of the config file to take the values of unset flags from"`
+
+	Address      []string          `default:"localhost:50051" env:"MCP_GRPC_ADDRESS" help:"Addresses of the replicas of the gRPC server, or a single target such as dns:///mcp.example.com:50051; sessions are balanced round-robin over the reachable ones"`
+	HealthCheck  bool              `help:"Only balance over the replicas which report SERVING through the gRPC health service"`
+	TLS          bool              `help:"Connect with TLS; implied by --ca-cert and --cert"`
+	CACert       string            `type:"path" help:"CA certificate to verify the server with, instead of the system roots"`
+	Cert         string            `type:"path" help:"Client certificate for mutual TLS"`
+	Key          string            `type:"path" help:"Key of the client certificate"`
+	ServerName   string            `help:"Name to verify the server certificate for, if not the host of the address"`
+	Header       map[string]string `mapsep:"none" placeholder:"KEY=VALUE" help:"Metadata to send with every stream"`
+	BearerToken  string            `env:"MCP_GRPC_TOKEN" help:"Bearer token to send in the authorization metadata"`
+	TokenCommand string            `help:"Shell command printing the bearer token, run for every new stream unless --bearer-token is set"`
+
+	KeepaliveTime    time.Duration `help:"Ping the server after this long without activity, to detect connections dropped by proxies; 0 disables pings"`
+	KeepaliveTimeout time.Duration `default:"20s" help:"Time to wait for a keepalive ping to be answered before closing the connection"`
+
+	RetryAttempts   int           `default:"3" help:"Attempts of an idempotent request whose stream broke, including the first; 1 disables retries"`
+	RetryBackoff    time.Duration `default:"100ms" help:"Delay before the first retry, doubled for every further one"`
+	RetryMaxBackoff time.Duration `default:"2s" help:"Upper bound of the retry delay"`
+	RetryBudget     float64       `default:"0.1" help:"Retries earned by every request sent, on top of a burst of 10, so that retries do not multiply the load on a failing server"`
+	RetryTool       []string      `placeholder:"NAME" help:"Tool which is safe to call again after its stream broke, as it has no side effects"`
+}
+
 var CLI struct {
-	Address string `default:"localhost:50051" help:"Address of the gRPC server to connect to"`
+	Globals
+
+	Stdio     stdioCmd     `cmd:"" default:"withargs" help:"Bridge JSON-RPC messages between stdin/stdout and the server (default)"`
+	Tools     toolsCmd     `cmd:"" help:"List and call the tools of the server"`
+	Resources resourcesCmd `cmd:"" help:"List and read the resources of the server"`
+	Prompts   promptsCmd   `cmd:"" help:"List and get the prompts of the server"`
+	Admin     adminCmd     `cmd:"" help:"Inspect and control the live sessions of a server"`
+	Replay    replayCmd    `cmd:"" help:"Replay a transcript against the server and diff the responses"`
+	Repl      replCmd      `cmd:"" help:"Explore the server interactively, with completion of tools and their arguments"`
+	Bench     benchCmd     `cmd:"" help:"Load test the server with a weighted mix of requests over concurrent streams"`
 }
 
 func main() {
-	kong.Parse(&CLI)
-	conn, err := grpc.NewClient(CLI.Address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	var p profiles
+	ctx := kong.Parse(&CLI, kong.Resolvers(&p), kong.WithBeforeResolve(p.load))
+	ctx.FatalIfErrorf(ctx.Run(&CLI.Globals))
+}
+
+type stdioCmd struct {
+	TraceParent       string        `env:"TRACEPARENT" help:"W3C traceparent to continue; a new trace is started if unset"`
+	TraceState        string        `env:"TRACESTATE" help:"W3C tracestate to propagate with the traceparent"`
+	Transcript        string        `help:"Append every message of the session to this JSON-lines transcript" type:"path"`
+	Reconnect         bool          `default:"true" negatable:"" help:"Reopen a broken stream and initialize the new session with the cached handshake"`
+	ReconnectDelay    time.Duration `default:"250ms" help:"Delay before the first reconnect attempt, doubled on every failed attempt"`
+	ReconnectMaxDelay time.Duration `default:"30s" help:"Upper bound of the reconnect delay"`
+	DrainTimeout      time.Duration `default:"30s" help:"Time to wait for outstanding responses once stdin is closed; 0 waits indefinitely"`
+	RequestTimeout    time.Duration `help:"Time to wait for the response to a request before answering it with an error and cancelling it on the server; 0 waits indefinitely"`
+}
+
+func (c *stdioCmd) Run(g *Globals) error {
+	conn, err := g.dial()
 	if err != nil {
-		log.Fatalf("did not connect: %v", err)
+		return fmt.Errorf("did not connect: %w", err)
 	}
 	defer conn.Close()
 
@@ -33,11 +87,28 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	stream, err := client.Transport(ctx)
-	if err != nil {
-		log.Fatalf("could not open stream: %v", err)
+	// The stream is a span of its own, and every request a child of it
+	parent, _ := tracing.Parse(c.TraceParent, c.TraceState)
+	trace := parent.Child()
+	ctx = metadata.AppendToOutgoingContext(ctx, tracing.TraceParentKey, trace.TraceParent)
+	if trace.TraceState != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, tracing.TraceStateKey, trace.TraceState)
+	}
+
+	var recorder *transcript.Recorder
+	if c.Transcript != "" {
+		if recorder, err = transcript.NewFile(c.Transcript); err != nil {
+			return err
+		}
+		defer recorder.Close()
 	}
 
+	b := newBridge(client, recorder)
+	b.reconnect = c.Reconnect
+	b.backoff = backoff{base: c.ReconnectDelay, max: c.ReconnectMaxDelay}
+	b.requestTimeout = c.RequestTimeout
+	b.retry = g.retryPolicy()
+
 	// Handle Ctrl+C
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
@@ -51,108 +122,48 @@ func main() {
 	go func() {
 		scanner := bufio.NewScanner(os.Stdin)
 		for scanner.Scan() {
-			line := scanner.Bytes()
-			var msg pb.GenericJSONRPCMessage
-			if err := json.Unmarshal(line, &msg); err != nil {
-				fmt.Fprintf(os.Stderr, "Invalid input: %v\n", err)
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
 				continue
 			}
-
-			typedId, err := getId(line)
+			msg, err := codec.Decode(line)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Failed to get ID: %v\n", err)
+				// Answer like a stdio server would, so that the IDE
+				// does not wait for a response which never comes
+				fmt.Fprintf(os.Stderr, "Invalid input: %v\n", err)
+				if e, ok := err.(*codec.Error); ok {
+					b.write(e.Response())
+				}
 				continue
 			}
 
-			msg.TypedId = typedId
-			// fmt.Printf("SENDING: %v\n", msg)
-			if err := stream.Send(&msg); err != nil {
-				fmt.Fprintf(os.Stderr, "Send error: %v\n", err)
-				return
+			if message.IsRequest(msg) {
+				if _, ok := tracing.FromMeta(msg.Params); !ok {
+					msg.Params = tracing.InjectMeta(msg.Params, trace.Child())
+				}
 			}
+			// fmt.Printf("SENDING: %v\n", msg)
+			b.send(ctx, msg)
 		}
 		if err := scanner.Err(); err != nil {
 			fmt.Fprintf(os.Stderr, "Scanner error: %v\n", err)
 		}
-		// time.Sleep(5 * time.Second)
-		// stream.CloseSend()
-	}()
-
-	// Read responses from server and print to stdout
-	for {
-		resp, err := stream.Recv()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Receive error: %v\n", err)
-			break
-		}
-		b, err := parseResp(resp)
-		// b, err := json.Marshal(resp)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Marshal error: %v\n", err)
-			continue
-		}
-		fmt.Println(string(b))
-	}
-
-	// Give time for goroutines to finish
-	// time.Sleep(5 * time.Second)
-}
-
-func getId(line []byte) (*pb.ID, error) {
-	var id *pb.ID
-	msg := &struct {
-		ID any `json:"id,omitempty"`
-	}{}
-
-	if err := json.Unmarshal(line, &msg); err != nil {
-		fmt.Fprintf(os.Stderr, "Unmarshal error: %v\n", err)
-		return id, err
-	}
-
-	switch v := msg.ID.(type) {
-	case string:
-		id = &pb.ID{Kind: &pb.ID_Str{Str: v}}
-	case int:
-		id = &pb.ID{Kind: &pb.ID_Num{Num: int64(v)}}
-	case float64:
-		id = &pb.ID{Kind: &pb.ID_Num{Num: int64(v)}}
-	case nil:
-		return nil, nil
-	default:
-		return id, fmt.Errorf("failed to infer 'id' type: '%T:%v'", v, v)
-	}
-
-	return id, nil
-}
-
-func parseResp(m *pb.GenericJSONRPCMessage) ([]byte, error) {
-	switch v := m.TypedId.GetKind().(type) {
-	case *pb.ID_Num:
-		type respType struct {
-			ID int64 `json:"id"`
-			*pb.GenericJSONRPCMessage
-		}
-
-		resp := respType{GenericJSONRPCMessage: m}
-		resp.ID = int64(v.Num)
-		resp.TypedId = nil
 
-		return json.Marshal(resp)
-	case *pb.ID_Str:
-		type respType struct {
-			ID string `json:"id"`
-			*pb.GenericJSONRPCMessage
+		// Let the server answer the outstanding requests and end the
+		// session, but give up on them after the drain timeout
+		b.closeSend()
+		if c.DrainTimeout > 0 {
+			time.AfterFunc(c.DrainTimeout, func() {
+				b.abandon(fmt.Sprintf("no response within %s after stdin was closed", c.DrainTimeout))
+				cancel()
+			})
 		}
+	}()
 
-		resp := respType{GenericJSONRPCMessage: m}
-		resp.ID = v.Str
-		resp.TypedId = nil
-
-		return json.Marshal(resp)
-	default:
-		return nil, fmt.Errorf("unsupported 'id' type in response: '%T:%v'", v, v)
+	// Read responses from server and print to stdout, reconnecting when
+	// the stream breaks
+	if err := b.run(ctx); err != nil {
+		return err
 	}
+	return b.unanswered()
 }
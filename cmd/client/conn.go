@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	grpc "google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	_ "google.golang.org/grpc/health"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+)
+
+// dial creates the connection to the server described by the flags, with
+// any further options
+func (g *Globals) dial(extra ...grpc.DialOption) (*grpc.ClientConn, error) {
+	creds := insecure.NewCredentials()
+	if g.TLS || g.CACert != "" || g.Cert != "" {
+		cfg, err := g.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		creds = credentials.NewTLS(cfg)
+	}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	if len(g.Header) > 0 || g.BearerToken != "" || g.TokenCommand != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(&streamMetadata{g}))
+	}
+	if g.KeepaliveTime > 0 {
+		// Servers reject pings more frequent than their enforcement policy
+		// allows, which defaults to one every 5 minutes
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{Time: g.KeepaliveTime, Timeout: g.KeepaliveTimeout}))
+	}
+
+	// Every stream is balanced on its own and stays on its replica, so
+	// that an MCP session is pinned to the replica it was initialized on
+	serviceConfig := `{"loadBalancingConfig": [{"round_robin": {}}]}`
+	if g.HealthCheck {
+		serviceConfig = `{"loadBalancingConfig": [{"round_robin": {}}], "healthCheckConfig": {"serviceName": ""}}`
+	}
+	opts = append(opts, grpc.WithDefaultServiceConfig(serviceConfig))
+	opts = append(opts, extra...)
+
+	target, err := g.target(&opts)
+	if err != nil {
+		return nil, err
+	}
+	return grpc.NewClient(target, opts...)
+}
+
+// target returns the target to dial. Several addresses are resolved to
+// themselves, each verified by TLS for its own host unless --server-name is
+// set.
+func (g *Globals) target(opts *[]grpc.DialOption) (string, error) {
+	switch len(g.Address) {
+	case 0:
+		return "", errors.New("no --address given")
+	case 1:
+		return g.Address[0], nil
+	}
+	addrs := make([]resolver.Address, len(g.Address))
+	for i, a := range g.Address {
+		host, _, err := net.SplitHostPort(a)
+		if err != nil {
+			return "", fmt.Errorf("--address %s: several addresses must each be host:port: %w", a, err)
+		}
+		addrs[i] = resolver.Address{Addr: a, ServerName: host}
+	}
+	r := manual.NewBuilderWithScheme("mcp-grpc")
+	r.InitialState(resolver.State{Addresses: addrs})
+	*opts = append(*opts, grpc.WithResolvers(r))
+	return r.Scheme() + ":///", nil
+}
+
+// backend returns the address of the replica a stream is pinned to, given
+// the context of the stream
+func backend(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok {
+		return p.Addr.String()
+	}
+	return "unknown"
+}
+
+func (g *Globals) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{ServerName: g.ServerName, MinVersion: tls.VersionTLS12}
+	if g.CACert != "" {
+		pem, err := os.ReadFile(g.CACert)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: no certificates found", g.CACert)
+		}
+	}
+	if g.Cert != "" || g.Key != "" {
+		if g.Cert == "" || g.Key == "" {
+			return nil, errors.New("--cert and --key must be given together")
+		}
+		cert, err := tls.LoadX509KeyPair(g.Cert, g.Key)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// streamMetadata adds the headers and the bearer token to every stream. An
+// authorization already set on the call, like the admin token, is kept.
+type streamMetadata struct {
+	g *Globals
+}
+
+func (m *streamMetadata) GetRequestMetadata(ctx context.Context, _ ...string) (map[string]string, error) {
+	md := make(map[string]string, len(m.g.Header)+1)
+	for k, v := range m.g.Header {
+		md[strings.ToLower(k)] = v
+	}
+	if out, _ := metadata.FromOutgoingContext(ctx); len(out.Get("authorization")) > 0 {
+		return md, nil
+	}
+
+	token := m.g.BearerToken
+	if token == "" && m.g.TokenCommand != "" {
+		var err error
+		if token, err = runTokenCommand(ctx, m.g.TokenCommand); err != nil {
+			return nil, err
+		}
+	}
+	if token != "" {
+		md["authorization"] = "Bearer " + token
+	}
+	return md, nil
+}
+
+// RequireTransportSecurity allows tokens over plaintext, for servers behind
+// a TLS-terminating proxy or on localhost
+func (m *streamMetadata) RequireTransportSecurity() bool {
+	return false
+}
+
+// runTokenCommand runs the command with the shell and returns the first line
+// of its output
+func runTokenCommand(ctx context.Context, command string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("token command: %w", err)
+	}
+	token, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	if token == "" {
+		return "", errors.New("token command printed no token")
+	}
+	return strings.TrimSpace(token), nil
+}
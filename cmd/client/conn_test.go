@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	pb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/jsonrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestDial_BalancesStreamsOverAddresses(t *testing.T) {
+	servers := map[string]*fakeServer{"a:50051": {}, "b:50051": {}}
+	listeners := map[string]*bufconn.Listener{}
+	for addr, srv := range servers {
+		listeners[addr] = listen(t, srv)
+	}
+	g := &Globals{Address: []string{"a:50051", "b:50051"}}
+	conn, err := g.dial(grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+		return listeners[addr].DialContext(ctx)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := pb.NewJSONRPCServiceClient(conn)
+
+	// Every stream is a session, answered by the replica it opened on
+	open := func() {
+		t.Helper()
+		stream, err := client.Transport(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := stream.Send(call(1, "echo")); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := stream.Recv(); err != nil {
+			t.Fatal(err)
+		}
+		stream.CloseSend()
+	}
+	counts := func() (a, b int) {
+		return servers["a:50051"].opened(), servers["b:50051"].opened()
+	}
+
+	// Streams go to the first replica connected until the other one is
+	deadline := time.Now().Add(5 * time.Second)
+	for a, b := counts(); a == 0 || b == 0; a, b = counts() {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected streams on both replicas, got %d and %d", a, b)
+		}
+		open()
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	a, b := counts()
+	for range 4 {
+		open()
+	}
+	if a2, b2 := counts(); a2-a != 2 || b2-b != 2 {
+		t.Errorf("expected 2 of 4 streams on each replica, got %d and %d", a2-a, b2-b)
+	}
+}
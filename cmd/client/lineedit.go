@@ -0,0 +1,378 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// maxHistory is the number of lines kept in the history file
+const maxHistory = 1000
+
+// errInterrupted is returned by readLine when Ctrl-C is pressed
+var errInterrupted = errors.New("interrupted")
+
+// editor reads lines from a terminal in raw mode, with cursor movement,
+// history and tab completion. Text printed with printAbove while a line is
+// being edited appears above it. When the input is not a terminal, plain
+// lines are read without a prompt.
+type editor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	fd       int
+	terminal bool
+	prompt   string
+	// complete returns the candidates for the last word of the text
+	// before the cursor, each of which starts with that word
+	complete func(before string) []string
+
+	mu      sync.Mutex
+	editing bool
+	line    []rune
+	pos     int
+	history []string
+	file    *os.File
+}
+
+func newEditor(in *os.File, out io.Writer, prompt string) *editor {
+	return &editor{
+		in:       bufio.NewReader(in),
+		out:      out,
+		fd:       int(in.Fd()),
+		terminal: isTerminal(int(in.Fd())),
+		prompt:   prompt,
+	}
+}
+
+// openHistory loads the history from path and appends the lines read from
+// now on to it
+func (e *editor) openHistory(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	var lines []string
+	for _, l := range strings.Split(string(b), "\n") {
+		if l != "" {
+			lines = append(lines, l)
+		}
+	}
+
+	// Rewrite a file which has grown well beyond the limit
+	flags := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	if len(lines) > 2*maxHistory {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	if len(lines) > maxHistory {
+		lines = lines[len(lines)-maxHistory:]
+	}
+	e.history = lines
+	if e.file, err = os.OpenFile(path, flags, 0o600); err != nil {
+		return err
+	}
+	if flags&os.O_TRUNC != 0 {
+		_, err = fmt.Fprintln(e.file, strings.Join(e.history, "\n"))
+	}
+	return err
+}
+
+func (e *editor) close() {
+	if e.file != nil {
+		e.file.Close()
+	}
+}
+
+// addHistory records a line, unless it repeats the previous one
+func (e *editor) addHistory(line string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if n := len(e.history); n > 0 && e.history[n-1] == line {
+		return
+	}
+	e.history = append(e.history, line)
+	if e.file != nil {
+		fmt.Fprintln(e.file, line)
+	}
+}
+
+// printAbove prints s, above the line being edited if there is one
+func (e *editor) printAbove(s string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !strings.HasSuffix(s, "\n") {
+		s += "\n"
+	}
+	if !e.editing {
+		io.WriteString(e.out, s)
+		return
+	}
+	io.WriteString(e.out, "\r\x1b[K"+s)
+	e.redraw()
+}
+
+// readLine reads the next line, and returns io.EOF at the end of the input
+// or when Ctrl-D is pressed on an empty line
+func (e *editor) readLine() (string, error) {
+	if !e.terminal {
+		line, err := e.in.ReadString('\n')
+		if err == io.EOF && line != "" {
+			err = nil
+		}
+		return strings.TrimRight(line, "\r\n"), err
+	}
+
+	restore, err := makeRaw(e.fd)
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+
+	e.mu.Lock()
+	e.editing, e.line, e.pos = true, nil, 0
+	e.redraw()
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		e.editing = false
+		e.mu.Unlock()
+	}()
+
+	h := len(e.history)
+	var draft []rune
+	for {
+		k, err := e.readKey()
+		if err != nil {
+			return "", err
+		}
+
+		e.mu.Lock()
+		switch k {
+		case keyEnter:
+			line := string(e.line)
+			io.WriteString(e.out, "\n")
+			e.mu.Unlock()
+			return line, nil
+		case keyInterrupt:
+			io.WriteString(e.out, "^C\n")
+			e.mu.Unlock()
+			return "", errInterrupted
+		case keyEOF:
+			if len(e.line) == 0 {
+				io.WriteString(e.out, "\n")
+				e.mu.Unlock()
+				return "", io.EOF
+			}
+			e.deleteRange(e.pos, e.pos+1)
+		case keyDelete:
+			e.deleteRange(e.pos, e.pos+1)
+		case keyBackspace:
+			e.deleteRange(e.pos-1, e.pos)
+		case keyLeft:
+			e.pos = max(e.pos-1, 0)
+		case keyRight:
+			e.pos = min(e.pos+1, len(e.line))
+		case keyHome:
+			e.pos = 0
+		case keyEnd:
+			e.pos = len(e.line)
+		case keyKillEnd:
+			e.line = e.line[:e.pos]
+		case keyKillStart:
+			e.deleteRange(0, e.pos)
+		case keyKillWord:
+			start := e.pos
+			for start > 0 && e.line[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && e.line[start-1] != ' ' {
+				start--
+			}
+			e.deleteRange(start, e.pos)
+		case keyUp, keyDown:
+			if h == len(e.history) {
+				draft = e.line
+			}
+			if k == keyUp && h > 0 {
+				h--
+			} else if k == keyDown && h < len(e.history) {
+				h++
+			}
+			if h == len(e.history) {
+				e.line = draft
+			} else {
+				e.line = []rune(e.history[h])
+			}
+			e.pos = len(e.line)
+		case keyClear:
+			io.WriteString(e.out, "\x1b[H\x1b[2J")
+		case keyTab:
+			e.completeWord()
+		case keyNone:
+		default:
+			e.insert(string(rune(k)))
+		}
+		e.redraw()
+		e.mu.Unlock()
+	}
+}
+
+type key rune
+
+// Keys other than printable characters, which are their own rune
+const (
+	keyNone key = -iota - 1
+	keyEnter
+	keyInterrupt
+	keyEOF
+	keyDelete
+	keyBackspace
+	keyLeft
+	keyRight
+	keyUp
+	keyDown
+	keyHome
+	keyEnd
+	keyKillEnd
+	keyKillStart
+	keyKillWord
+	keyClear
+	keyTab
+)
+
+var controlKeys = map[rune]key{
+	'\r':   keyEnter,
+	'\n':   keyEnter,
+	'\t':   keyTab,
+	0x01:   keyHome,      // Ctrl-A
+	0x02:   keyLeft,      // Ctrl-B
+	0x03:   keyInterrupt, // Ctrl-C
+	0x04:   keyEOF,       // Ctrl-D
+	0x05:   keyEnd,       // Ctrl-E
+	0x06:   keyRight,     // Ctrl-F
+	0x08:   keyBackspace, // Ctrl-H
+	0x0b:   keyKillEnd,   // Ctrl-K
+	0x0c:   keyClear,     // Ctrl-L
+	0x0e:   keyDown,      // Ctrl-N
+	0x10:   keyUp,        // Ctrl-P
+	0x15:   keyKillStart, // Ctrl-U
+	0x17:   keyKillWord,  // Ctrl-W
+	0x7f:   keyBackspace,
+	0xfffd: keyNone,
+}
+
+// escapeKeys are the final bytes of the CSI and SS3 sequences of the keys
+var escapeKeys = map[string]key{
+	"A": keyUp, "B": keyDown, "C": keyRight, "D": keyLeft,
+	"H": keyHome, "F": keyEnd, "1~": keyHome, "7~": keyHome,
+	"4~": keyEnd, "8~": keyEnd, "3~": keyDelete,
+}
+
+func (e *editor) readKey() (key, error) {
+	r, _, err := e.in.ReadRune()
+	if err != nil {
+		return keyNone, err
+	}
+	if r != 0x1b {
+		if k, ok := controlKeys[r]; ok {
+			return k, nil
+		}
+		if r < ' ' {
+			return keyNone, nil
+		}
+		return key(r), nil
+	}
+
+	// ESC [ params final, or ESC O final
+	b, err := e.in.ReadByte()
+	if err != nil || (b != '[' && b != 'O') {
+		return keyNone, err
+	}
+	var seq []byte
+	for {
+		c, err := e.in.ReadByte()
+		if err != nil {
+			return keyNone, err
+		}
+		seq = append(seq, c)
+		if c >= 0x40 && c <= 0x7e {
+			break
+		}
+	}
+	s := string(seq)
+	if i := strings.LastIndexByte(s, ';'); i >= 0 && s[len(s)-1] != '~' {
+		s = s[len(s)-1:] // drop the modifiers of e.g. Ctrl-Right
+	}
+	if k, ok := escapeKeys[s]; ok {
+		return k, nil
+	}
+	return keyNone, nil
+}
+
+func (e *editor) insert(s string) {
+	r := []rune(s)
+	e.line = append(e.line[:e.pos], append(r, e.line[e.pos:]...)...)
+	e.pos += len(r)
+}
+
+func (e *editor) deleteRange(from, to int) {
+	if from < 0 || to > len(e.line) || from >= to {
+		return
+	}
+	e.line = append(e.line[:from], e.line[to:]...)
+	e.pos = from
+}
+
+// completeWord completes the word before the cursor. A single candidate is
+// inserted, followed by a space unless it ends with "="; several extend the
+// word to their common prefix, or are listed if they do not.
+func (e *editor) completeWord() {
+	if e.complete == nil {
+		return
+	}
+	before := string(e.line[:e.pos])
+	word := before[strings.LastIndexByte(before, ' ')+1:]
+	var candidates []string
+	for _, c := range e.complete(before) {
+		if strings.HasPrefix(c, word) {
+			candidates = append(candidates, c)
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		io.WriteString(e.out, "\a")
+	case 1:
+		c := candidates[0]
+		if !strings.HasSuffix(c, "=") {
+			c += " "
+		}
+		e.insert(c[len(word):])
+	default:
+		prefix := candidates[0]
+		for _, c := range candidates[1:] {
+			for !strings.HasPrefix(c, prefix) {
+				_, size := utf8.DecodeLastRuneInString(prefix)
+				prefix = prefix[:len(prefix)-size]
+			}
+		}
+		if len(prefix) > len(word) {
+			e.insert(prefix[len(word):])
+			return
+		}
+		io.WriteString(e.out, "\r\x1b[K"+strings.Join(candidates, "  ")+"\n")
+	}
+}
+
+// redraw writes the prompt and the line, and puts the cursor in place
+func (e *editor) redraw() {
+	s := "\r" + e.prompt + string(e.line) + "\x1b[K"
+	if n := len(e.line) - e.pos; n > 0 {
+		s += fmt.Sprintf("\x1b[%dD", n)
+	}
+	io.WriteString(e.out, s)
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/client"
+)
+
+// mcpFlags are shared by the tools, resources and prompts subcommands
+type mcpFlags struct {
+	Output  string        `short:"o" enum:"text,json" default:"text" help:"Output format: text or json"`
+	Timeout time.Duration `default:"30s" help:"Timeout of the command, including the initialize handshake"`
+}
+
+// session runs fn on an initialized session within the command timeout
+func (f *mcpFlags) session(g *Globals, fn func(ctx context.Context, s *mcpSession) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), f.Timeout)
+	defer cancel()
+	s, err := openSession(ctx, g, nil, g.retryPolicy())
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	return fn(ctx, s)
+}
+
+func (f *mcpFlags) json() bool {
+	return f.Output == "json"
+}
+
+type toolsCmd struct {
+	mcpFlags
+
+	List toolsListCmd `cmd:"" help:"List the tools of the server"`
+	Call toolsCallCmd `cmd:"" help:"Call a tool and print its result"`
+}
+
+type toolsListCmd struct{}
+
+type toolsCallCmd struct {
+	Name string            `arg:"" help:"Name of the tool"`
+	Arg  map[string]string `short:"a" mapsep:"none" placeholder:"KEY=VALUE" help:"Argument of the tool; converted to the type of its input schema"`
+	JSON string            `help:"Arguments of the tool as a JSON object; --arg takes precedence"`
+}
+
+type resourcesCmd struct {
+	mcpFlags
+
+	List resourcesListCmd `cmd:"" help:"List the resources of the server"`
+	Read resourcesReadCmd `cmd:"" help:"Read a resource and print its contents"`
+}
+
+type resourcesListCmd struct{}
+
+type resourcesReadCmd struct {
+	URI string `arg:"" help:"URI of the resource"`
+}
+
+type promptsCmd struct {
+	mcpFlags
+
+	List promptsListCmd `cmd:"" help:"List the prompts of the server"`
+	Get  promptsGetCmd  `cmd:"" help:"Get a prompt and print its messages"`
+}
+
+type promptsListCmd struct{}
+
+type promptsGetCmd struct {
+	Name string            `arg:"" help:"Name of the prompt"`
+	Arg  map[string]string `short:"a" mapsep:"none" placeholder:"KEY=VALUE" help:"Argument of the prompt"`
+}
+
+// inputSchema is the part of a tool's JSON schema which the arguments given
+// on the command line are converted and checked with
+type inputSchema struct {
+	Properties map[string]struct {
+		Type        any    `json:"type"`
+		Description string `json:"description"`
+		Enum        []any  `json:"enum"`
+	} `json:"properties"`
+	Required             []string `json:"required"`
+	AdditionalProperties any      `json:"additionalProperties"`
+}
+
+func schemaOf(t *client.Tool) (*inputSchema, error) {
+	var schema inputSchema
+	if len(t.InputSchema) == 0 {
+		return &schema, nil
+	}
+	if err := json.Unmarshal(t.InputSchema, &schema); err != nil {
+		return nil, fmt.Errorf("input schema of tool %q: %w", t.Name, err)
+	}
+	return &schema, nil
+}
+
+func printJSON(v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(b))
+	return nil
+}
+
+func (c *toolsListCmd) Run(g *Globals, t *toolsCmd) error {
+	return t.session(g, func(ctx context.Context, s *mcpSession) error {
+		tools, err := s.ListTools(ctx)
+		if err != nil {
+			return err
+		}
+		if t.json() {
+			return printJSON(map[string]any{"tools": tools})
+		}
+		return printTools(tools)
+	})
+}
+
+func (c *toolsCallCmd) Run(g *Globals, t *toolsCmd) error {
+	args := map[string]any{}
+	if c.JSON != "" {
+		if err := json.Unmarshal([]byte(c.JSON), &args); err != nil {
+			return fmt.Errorf("--json must be a JSON object: %w", err)
+		}
+	}
+
+	return t.session(g, func(ctx context.Context, s *mcpSession) error {
+		if len(c.Arg) > 0 {
+			tools, err := s.ListTools(ctx)
+			if err != nil {
+				return err
+			}
+			i := slices.IndexFunc(tools, func(tl client.Tool) bool { return tl.Name == c.Name })
+			if i < 0 {
+				return fmt.Errorf("unknown tool %q", c.Name)
+			}
+			schema, err := schemaOf(&tools[i])
+			if err != nil {
+				return err
+			}
+			for k, v := range c.Arg {
+				val, err := coerce(schema.Properties[k].Type, v)
+				if err != nil {
+					return fmt.Errorf("argument %s: %w", k, err)
+				}
+				args[k] = val
+			}
+		}
+
+		result, err := s.CallTool(ctx, c.Name, args)
+		if err != nil {
+			return err
+		}
+		if t.json() {
+			if err := printJSON(result); err != nil {
+				return err
+			}
+		} else {
+			printContent(result.Content)
+		}
+		if result.IsError {
+			return fmt.Errorf("tool %q returned an error", c.Name)
+		}
+		return nil
+	})
+}
+
+func (c *resourcesListCmd) Run(g *Globals, r *resourcesCmd) error {
+	return r.session(g, func(ctx context.Context, s *mcpSession) error {
+		resources, err := s.ListResources(ctx)
+		if err != nil {
+			return err
+		}
+		if r.json() {
+			return printJSON(map[string]any{"resources": resources})
+		}
+		return printResources(resources)
+	})
+}
+
+func (c *resourcesReadCmd) Run(g *Globals, r *resourcesCmd) error {
+	return r.session(g, func(ctx context.Context, s *mcpSession) error {
+		result, err := s.ReadResource(ctx, c.URI)
+		if err != nil {
+			return err
+		}
+		if r.json() {
+			return printJSON(result)
+		}
+		printResourceContents(result)
+		return nil
+	})
+}
+
+func (c *promptsListCmd) Run(g *Globals, p *promptsCmd) error {
+	return p.session(g, func(ctx context.Context, s *mcpSession) error {
+		prompts, err := s.ListPrompts(ctx)
+		if err != nil {
+			return err
+		}
+		if p.json() {
+			return printJSON(map[string]any{"prompts": prompts})
+		}
+		return printPrompts(prompts)
+	})
+}
+
+func (c *promptsGetCmd) Run(g *Globals, p *promptsCmd) error {
+	return p.session(g, func(ctx context.Context, s *mcpSession) error {
+		result, err := s.GetPrompt(ctx, c.Name, c.Arg)
+		if err != nil {
+			return err
+		}
+		if p.json() {
+			return printJSON(result)
+		}
+		printPromptMessages(result)
+		return nil
+	})
+}
+
+func printTools(tools []client.Tool) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tARGUMENTS\tDESCRIPTION")
+	for _, tl := range tools {
+		var args []string
+		schema, err := schemaOf(&tl)
+		if err != nil {
+			schema = &inputSchema{}
+		}
+		for name := range schema.Properties {
+			if slices.Contains(schema.Required, name) {
+				name += "*"
+			}
+			args = append(args, name)
+		}
+		sort.Strings(args)
+		fmt.Fprintf(w, "%s\t%s\t%s\n", tl.Name, orDash(strings.Join(args, ",")), firstLine(tl.Description))
+	}
+	return w.Flush()
+}
+
+func printResources(resources []client.Resource) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "URI\tNAME\tMIME TYPE")
+	for _, res := range resources {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", res.URI, orDash(res.Name), orDash(res.MimeType))
+	}
+	return w.Flush()
+}
+
+func printPrompts(prompts []client.Prompt) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tARGUMENTS\tDESCRIPTION")
+	for _, pr := range prompts {
+		var args []string
+		for _, a := range pr.Arguments {
+			if a.Required {
+				a.Name += "*"
+			}
+			args = append(args, a.Name)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", pr.Name, orDash(strings.Join(args, ",")), firstLine(pr.Description))
+	}
+	return w.Flush()
+}
+
+// printResourceContents prints the result of resources/read
+func printResourceContents(result *client.ReadResourceResult) {
+	for _, item := range result.Contents {
+		if item.Blob != "" {
+			fmt.Printf("[%s: %s, %d bytes]\n", item.URI, orDash(item.MimeType), base64.StdEncoding.DecodedLen(len(item.Blob)))
+			continue
+		}
+		printText(item.Text)
+	}
+}
+
+// printPromptMessages prints the result of prompts/get
+func printPromptMessages(result *client.GetPromptResult) {
+	for _, m := range result.Messages {
+		fmt.Printf("%s:\n", m.Role)
+		printContent([]client.Content{m.Content})
+	}
+}
+
+// coerce converts a --arg value to the JSON type of the argument's schema.
+// Without a single known type, JSON values are taken as such and anything
+// else as a string.
+func coerce(typ any, v string) (any, error) {
+	switch typ {
+	case "string":
+		return v, nil
+	case "integer":
+		return strconv.ParseInt(v, 10, 64)
+	case "number":
+		return strconv.ParseFloat(v, 64)
+	case "boolean":
+		return strconv.ParseBool(v)
+	case "object", "array":
+		var val any
+		if err := json.Unmarshal([]byte(v), &val); err != nil {
+			return nil, fmt.Errorf("not valid JSON: %w", err)
+		}
+		return val, nil
+	}
+	var val any
+	if err := json.Unmarshal([]byte(v), &val); err == nil {
+		return val, nil
+	}
+	return v, nil
+}
+
+func printContent(items []client.Content) {
+	for _, c := range items {
+		switch c.Type {
+		case "text":
+			printText(c.Text)
+		case "image", "audio":
+			fmt.Printf("[%s: %s, %d bytes]\n", c.Type, orDash(c.MimeType), base64.StdEncoding.DecodedLen(len(c.Data)))
+		case "resource":
+			if c.Resource != nil {
+				fmt.Printf("[resource: %s]\n", c.Resource.URI)
+				printText(c.Resource.Text)
+			}
+		case "resource_link":
+			fmt.Printf("[resource link: %s]\n", c.URI)
+		default:
+			fmt.Printf("[%s]\n", c.Type)
+		}
+	}
+}
+
+func printText(s string) {
+	if s == "" {
+		return
+	}
+	fmt.Print(s)
+	if !strings.HasSuffix(s, "\n") {
+		fmt.Println()
+	}
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return orDash(line)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/client"
+)
+
+func TestCoerce(t *testing.T) {
+	tests := []struct {
+		typ     any
+		value   string
+		want    any
+		wantErr bool
+	}{
+		{typ: "string", value: "42", want: "42"},
+		{typ: "integer", value: "42", want: int64(42)},
+		{typ: "integer", value: "4.2", wantErr: true},
+		{typ: "number", value: "4.2", want: 4.2},
+		{typ: "boolean", value: "true", want: true},
+		{typ: "boolean", value: "yes", wantErr: true},
+		{typ: "object", value: `{"a":1}`, want: map[string]any{"a": 1.0}},
+		{typ: "array", value: `[1`, wantErr: true},
+		// Without a single known type, JSON values are taken as such
+		{typ: nil, value: "42", want: 42.0},
+		{typ: nil, value: "hello", want: "hello"},
+		{typ: nil, value: `"quoted"`, want: "quoted"},
+		{typ: []any{"string", "null"}, value: "null", want: nil},
+	}
+	for _, tt := range tests {
+		got, err := coerce(tt.typ, tt.value)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("coerce(%v, %q): expected an error, got %#v", tt.typ, tt.value, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("coerce(%v, %q): %v", tt.typ, tt.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("coerce(%v, %q): expected %#v, got %#v", tt.typ, tt.value, tt.want, got)
+		}
+	}
+}
+
+func TestSchemaOf(t *testing.T) {
+	schema, err := schemaOf(&client.Tool{Name: "hello", InputSchema: json.RawMessage(`{"type":"object","properties":{"name":{"type":"string","enum":["Ann","Bob"]}},"required":["name"]}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := schema.Properties["name"]; p.Type != "string" || len(p.Enum) != 2 || len(schema.Required) != 1 {
+		t.Errorf("expected the name property to be read, got %+v", schema)
+	}
+
+	if schema, err := schemaOf(&client.Tool{Name: "none"}); err != nil || len(schema.Properties) != 0 {
+		t.Errorf("expected an empty schema for a tool without one, got %+v, %v", schema, err)
+	}
+	if _, err := schemaOf(&client.Tool{Name: "broken", InputSchema: json.RawMessage(`[]`)}); err == nil {
+		t.Error("expected an error for a schema which is not an object")
+	}
+}
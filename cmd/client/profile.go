@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/kong"
+	"gopkg.in/yaml.v3"
+)
+
+// config is the config file of the client. A profile maps flag names, as
+// on the command line without the dashes, to their values:
+//
+//	profiles:
+//	  staging:
+//	    address: mcp.staging.example.com:443
+//	    tls: true
+//	    token-command: vault read -field=token secret/mcp
+//	    header:
+//	      x-tenant: acme
+//	    timeout: 10s
+type config struct {
+	Profiles map[string]map[string]any `yaml:"profiles"`
+}
+
+// profiles gives the flags which are neither on the command line nor set
+// through their environment variable the values of the selected profile.
+// It is a kong resolver, and its load method a BeforeResolve hook.
+type profiles struct {
+	loaded  bool
+	profile map[string]any
+}
+
+func (p *profiles) load(kctx *kong.Context) error {
+	if p.loaded {
+		return nil
+	}
+	p.loaded = true
+	var err error
+	p.profile, err = loadProfile(kctx)
+	return err
+}
+
+func (p *profiles) Validate(*kong.Application) error {
+	return nil
+}
+
+func (p *profiles) Resolve(kctx *kong.Context, parent *kong.Path, flag *kong.Flag) (any, error) {
+	for _, env := range flag.Tag.Envs {
+		if _, ok := os.LookupEnv(env); ok {
+			return nil, nil
+		}
+	}
+	return p.profile[flag.Name], nil
+}
+
+// loadProfile reads the profile named by --profile from the config file. A
+// missing config file is only an error if a profile is asked for.
+func loadProfile(kctx *kong.Context) (map[string]any, error) {
+	var name, path string
+	for _, f := range kctx.Flags() {
+		switch f.Name {
+		case "profile":
+			name, _ = kctx.FlagValue(f).(string)
+		case "config":
+			path, _ = kctx.FlagValue(f).(string)
+		}
+	}
+	if name == "" {
+		return nil, nil
+	}
+	path = kong.ExpandPath(path)
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("profile %q: %w", name, err)
+	}
+	var cfg config
+	if err := yaml.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	profile, ok := cfg.Profiles[name]
+	if !ok {
+		names := make([]string, 0, len(cfg.Profiles))
+		for n := range cfg.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("%s has no profile %q; profiles: %s", path, name, strings.Join(names, ", "))
+	}
+
+	// Catch typos, which would otherwise leave a setting silently unused
+	known := map[string]bool{}
+	var walk func(n *kong.Node)
+	walk = func(n *kong.Node) {
+		for _, f := range n.Flags {
+			known[f.Name] = true
+		}
+		for _, c := range n.Children {
+			walk(c)
+		}
+	}
+	walk(kctx.Model.Node)
+	for k := range profile {
+		if !known[k] || k == "config" || k == "profile" {
+			return nil, fmt.Errorf("%s: profile %q: unknown setting %q", path, name, k)
+		}
+	}
+	return profile, nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/kong"
+)
+
+const testConfig = `profiles:
+  staging:
+    address: staging.example.com:443
+    tls: true
+    header:
+      x-tenant: acme
+    request-timeout: 10s
+  typo:
+    adress: localhost:50052
+`
+
+func TestProfiles_Resolve(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(testConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		address []string
+		tls     bool
+		header  map[string]string
+		timeout time.Duration
+		err     string
+	}{
+		{
+			name:    "no profile",
+			args:    []string{"stdio"},
+			address: []string{"localhost:50051"},
+		},
+		{
+			name:    "profile",
+			args:    []string{"--profile", "staging", "stdio"},
+			address: []string{"staging.example.com:443"},
+			tls:     true,
+			header:  map[string]string{"x-tenant": "acme"},
+			timeout: 10 * time.Second,
+		},
+		{
+			name:    "profile from the environment",
+			args:    []string{"stdio"},
+			env:     map[string]string{"MCP_GRPC_PROFILE": "staging"},
+			address: []string{"staging.example.com:443"},
+			tls:     true,
+			header:  map[string]string{"x-tenant": "acme"},
+			timeout: 10 * time.Second,
+		},
+		{
+			name:    "flags win over the profile",
+			args:    []string{"--profile", "staging", "--address", "localhost:50052", "--tls=false", "stdio", "--request-timeout", "1s"},
+			address: []string{"localhost:50052"},
+			header:  map[string]string{"x-tenant": "acme"},
+			timeout: time.Second,
+		},
+		{
+			name:    "environment variables win over the profile",
+			args:    []string{"--profile", "staging", "stdio"},
+			env:     map[string]string{"MCP_GRPC_ADDRESS": "localhost:50052"},
+			address: []string{"localhost:50052"},
+			tls:     true,
+			header:  map[string]string{"x-tenant": "acme"},
+			timeout: 10 * time.Second,
+		},
+		{
+			name: "unknown setting",
+			args: []string{"--profile", "typo", "stdio"},
+			err:  `profile "typo": unknown setting "adress"`,
+		},
+		{
+			name: "unknown profile",
+			args: []string{"--profile", "prod", "stdio"},
+			err:  `has no profile "prod"; profiles: staging, typo`,
+		},
+		{
+			name: "missing config file",
+			args: []string{"--config", filepath.Join(t.TempDir(), "missing.yaml"), "--profile", "staging", "stdio"},
+			err:  `profile "staging": open`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, env := range []string{"MCP_GRPC_CONFIG", "MCP_GRPC_PROFILE", "MCP_GRPC_ADDRESS"} {
+				t.Setenv(env, "")
+				os.Unsetenv(env)
+			}
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			cli := CLI
+			var p profiles
+			parser, err := kong.New(&cli, kong.Resolvers(&p), kong.WithBeforeResolve(p.load))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = parser.Parse(append([]string{"--config", path}, tt.args...))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected an error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !slices.Equal(cli.Address, tt.address) {
+				t.Errorf("expected the address %v, got %v", tt.address, cli.Address)
+			}
+			if cli.TLS != tt.tls {
+				t.Errorf("expected TLS %v, got %v", tt.tls, cli.TLS)
+			}
+			if len(cli.Header) != len(tt.header) || cli.Header["x-tenant"] != tt.header["x-tenant"] {
+				t.Errorf("expected the headers %v, got %v", tt.header, cli.Header)
+			}
+			if cli.Stdio.RequestTimeout != tt.timeout {
+				t.Errorf("expected the request timeout %s, got %s", tt.timeout, cli.Stdio.RequestTimeout)
+			}
+		})
+	}
+}
@@ -0,0 +1,647 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/client"
+)
+
+type replCmd struct {
+	History   string        `default:"~/.mcp-grpc-history" type:"path" help:"File to keep the history of entered lines in"`
+	NoHistory bool          `help:"Neither read nor write the history file"`
+	Timeout   time.Duration `default:"60s" help:"Timeout of each command"`
+}
+
+// replCommands are the commands of the REPL, in the order of its help
+var replCommands = []struct{ name, usage, help string }{
+	{"tools", "tools", "List the tools, refreshing the cached list"},
+	{"describe", "describe <tool>", "Show the arguments of a tool"},
+	{"call", "call <tool> [name=value ...] | call <tool> {json}", "Call a tool"},
+	{"resources", "resources", "List the resources"},
+	{"read", "read <uri>", "Read a resource"},
+	{"prompts", "prompts", "List the prompts"},
+	{"prompt", "prompt <name> [name=value ...]", "Get a prompt"},
+	{"raw", "raw <method> [{json}]", "Send any request and print its result as JSON"},
+	{"help", "help", "Show this help"},
+	{"exit", "exit", "Leave the REPL, as does Ctrl-D"},
+}
+
+// errExit ends the REPL
+var errExit = errors.New("exit")
+
+type repl struct {
+	ed      *editor
+	timeout time.Duration
+
+	mu      sync.Mutex
+	session *mcpSession
+	// replaced is closed when the session is replaced after it ended
+	replaced chan struct{}
+	tools    []client.Tool
+	prompts  []client.Prompt
+	cancel   context.CancelFunc
+}
+
+func (c *replCmd) Run(g *Globals) error {
+	ed := newEditor(os.Stdin, os.Stdout, "mcp> ")
+	defer ed.close()
+	r := &repl{ed: ed, timeout: c.Timeout, replaced: make(chan struct{})}
+	ed.complete = r.complete
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s, err := openSession(ctx, g, r.notification, g.retryPolicy())
+	if err != nil {
+		return err
+	}
+	r.session = s
+	defer func() { r.current().close() }()
+
+	// Prompts are optional, and only cached for completion
+	listCtx, listCancel := context.WithTimeout(ctx, c.Timeout)
+	if err := r.refresh(listCtx, true, false); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	r.refresh(listCtx, false, true)
+	listCancel()
+	if ed.terminal && !c.NoHistory {
+		if err := ed.openHistory(c.History); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: history: %v\n", err)
+		}
+		fmt.Printf("Connected to %s with %d tools. Type help for the commands, and Tab to complete them.\n", s.backend(), len(r.tools))
+	}
+
+	// Ctrl-C cancels the running command, or exits between commands. While
+	// a line is edited, the terminal delivers it as a key instead.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	defer signal.Stop(sigs)
+	go func() {
+		for range sigs {
+			r.mu.Lock()
+			running := r.cancel
+			r.mu.Unlock()
+			if running == nil {
+				os.Exit(130)
+			}
+			running()
+		}
+	}()
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			r.mu.Lock()
+			s, replaced := r.session, r.replaced
+			r.mu.Unlock()
+			select {
+			case <-s.Done():
+				ed.printAbove(fmt.Sprintf("Session on %s ended: %v", s.backend(), s.Err()))
+			case <-stop:
+				return
+			}
+			// The next command fails over to a new session
+			select {
+			case <-replaced:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	for {
+		line, err := ed.readLine()
+		if errors.Is(err, errInterrupted) {
+			continue
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := r.failover(ctx); err != nil {
+			if !retryable(err) {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Error: could not open a new session: %v\n", err)
+			continue
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if ed.terminal {
+			ed.addHistory(line)
+		}
+		if err := r.exec(ctx, line); errors.Is(err, errExit) {
+			return nil
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+	}
+}
+
+// exec runs a line of input within the command timeout
+func (r *repl) exec(ctx context.Context, line string) error {
+	words, err := splitWords(line)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	r.mu.Lock()
+	r.cancel = cancel
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		r.cancel = nil
+		r.mu.Unlock()
+	}()
+
+	cmd, args := words[0], words[1:]
+	switch cmd {
+	case "tools":
+		if err := r.refresh(ctx, true, false); err != nil {
+			return err
+		}
+		return printTools(r.cachedTools())
+	case "describe":
+		if len(args) != 1 {
+			return usage(cmd)
+		}
+		t, err := r.tool(args[0])
+		if err != nil {
+			return err
+		}
+		return describe(t)
+	case "call":
+		if len(args) == 0 {
+			return usage(cmd)
+		}
+		t, err := r.tool(args[0])
+		if err != nil {
+			return err
+		}
+		params, err := toolArguments(t, args[1:])
+		if err != nil {
+			return err
+		}
+		result, err := r.current().CallTool(ctx, t.Name, params)
+		if err != nil {
+			return err
+		}
+		printContent(result.Content)
+		if result.IsError {
+			return fmt.Errorf("tool %q returned an error", t.Name)
+		}
+		return nil
+	case "resources":
+		resources, err := r.current().ListResources(ctx)
+		if err != nil {
+			return err
+		}
+		return printResources(resources)
+	case "read":
+		if len(args) != 1 {
+			return usage(cmd)
+		}
+		result, err := r.current().ReadResource(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		printResourceContents(result)
+		return nil
+	case "prompts":
+		if err := r.refresh(ctx, false, true); err != nil {
+			return err
+		}
+		return printPrompts(r.cachedPrompts())
+	case "prompt":
+		if len(args) == 0 {
+			return usage(cmd)
+		}
+		params := map[string]string{}
+		for _, a := range args[1:] {
+			k, v, ok := strings.Cut(a, "=")
+			if !ok {
+				return fmt.Errorf("argument %q must be name=value", a)
+			}
+			params[k] = v
+		}
+		result, err := r.current().GetPrompt(ctx, args[0], params)
+		if err != nil {
+			return err
+		}
+		printPromptMessages(result)
+		return nil
+	case "raw":
+		if len(args) == 0 || len(args) > 2 {
+			return usage(cmd)
+		}
+		var params map[string]any
+		if len(args) == 2 {
+			if err := json.Unmarshal([]byte(args[1]), &params); err != nil {
+				return fmt.Errorf("params must be a JSON object: %w", err)
+			}
+		}
+		var raw json.RawMessage
+		if err := r.current().Call(ctx, args[0], params, &raw); err != nil {
+			return err
+		}
+		return printJSON(raw)
+	case "help":
+		for _, c := range replCommands {
+			fmt.Printf("  %-50s %s\n", c.usage, c.help)
+		}
+		return nil
+	case "exit", "quit":
+		return errExit
+	}
+	return fmt.Errorf("unknown command %q; type help for the commands", cmd)
+}
+
+func usage(cmd string) error {
+	for _, c := range replCommands {
+		if c.name == cmd {
+			return fmt.Errorf("usage: %s", c.usage)
+		}
+	}
+	return nil
+}
+
+func (r *repl) current() *mcpSession {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.session
+}
+
+// failover replaces an ended session with a new one on a reachable replica,
+// whose tools and prompts are fetched again. A session terminated on
+// purpose, or one whose credentials were rejected, is not replaced.
+func (r *repl) failover(ctx context.Context) error {
+	s := r.current()
+	select {
+	case <-s.Done():
+	default:
+		return nil
+	}
+	if err := s.Err(); !retryable(err) {
+		return err
+	}
+	next, err := s.reopen(ctx, r.timeout)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.session = next
+	close(r.replaced)
+	r.replaced = make(chan struct{})
+	r.mu.Unlock()
+	r.ed.printAbove(fmt.Sprintf("Failed over to a new session on %s", next.backend()))
+
+	listCtx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	r.refresh(listCtx, true, true)
+	return nil
+}
+
+// refresh updates the cached lists of tools and prompts
+func (r *repl) refresh(ctx context.Context, tools, prompts bool) error {
+	var errs []error
+	if tools {
+		t, err := r.current().ListTools(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("tools/list: %w", err))
+		} else {
+			r.mu.Lock()
+			r.tools = t
+			r.mu.Unlock()
+		}
+	}
+	if prompts {
+		p, err := r.current().ListPrompts(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("prompts/list: %w", err))
+		} else {
+			r.mu.Lock()
+			r.prompts = p
+			r.mu.Unlock()
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *repl) cachedTools() []client.Tool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.tools
+}
+
+func (r *repl) cachedPrompts() []client.Prompt {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.prompts
+}
+
+func (r *repl) tool(name string) (*client.Tool, error) {
+	tools := r.cachedTools()
+	if i := slices.IndexFunc(tools, func(t client.Tool) bool { return t.Name == name }); i >= 0 {
+		return &tools[i], nil
+	}
+	return nil, fmt.Errorf("unknown tool %q; run tools to refresh the list", name)
+}
+
+// notification shows a notification of the server, and refreshes the cached
+// lists when they change
+func (r *repl) notification(n client.Notification) {
+	r.ed.printAbove(fmt.Sprintf("<- %s %s", n.Method, n.Params))
+
+	tools, prompts := n.Method == "notifications/tools/list_changed", n.Method == "notifications/prompts/list_changed"
+	if tools || prompts {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+			defer cancel()
+			r.refresh(ctx, tools, prompts)
+		}()
+	}
+}
+
+// complete returns the candidates for the last word of before: commands,
+// then tool or prompt names, then their argument names and enum values
+func (r *repl) complete(before string) []string {
+	words, err := splitWords(before)
+	if err != nil {
+		return nil
+	}
+	prefix := ""
+	if len(words) > 0 && !strings.HasSuffix(before, " ") {
+		prefix, words = words[len(words)-1], words[:len(words)-1]
+	}
+
+	var candidates []string
+	switch {
+	case len(words) == 0:
+		for _, c := range replCommands {
+			candidates = append(candidates, c.name)
+		}
+	case len(words) == 1 && (words[0] == "call" || words[0] == "describe"):
+		for _, t := range r.cachedTools() {
+			candidates = append(candidates, t.Name)
+		}
+	case len(words) == 1 && words[0] == "prompt":
+		for _, p := range r.cachedPrompts() {
+			candidates = append(candidates, p.Name)
+		}
+	case words[0] == "call":
+		t, err := r.tool(words[1])
+		if err != nil {
+			return nil
+		}
+		schema, err := schemaOf(t)
+		if err != nil {
+			return nil
+		}
+		if name, _, ok := strings.Cut(prefix, "="); ok {
+			for _, v := range schema.Properties[name].Enum {
+				candidates = append(candidates, name+"="+fmt.Sprint(v))
+			}
+			break
+		}
+		for name := range schema.Properties {
+			if !given(words[2:], name) {
+				candidates = append(candidates, name+"=")
+			}
+		}
+	case words[0] == "prompt":
+		prompts := r.cachedPrompts()
+		i := slices.IndexFunc(prompts, func(p client.Prompt) bool { return p.Name == words[1] })
+		if i < 0 {
+			return nil
+		}
+		for _, a := range prompts[i].Arguments {
+			if !given(words[2:], a.Name) {
+				candidates = append(candidates, a.Name+"=")
+			}
+		}
+	}
+
+	var matches []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, prefix) {
+			matches = append(matches, c)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// given reports whether an argument is among the name=value words
+func given(words []string, name string) bool {
+	return slices.ContainsFunc(words, func(w string) bool { return strings.HasPrefix(w, name+"=") })
+}
+
+// toolArguments builds the arguments of a tool call from name=value words,
+// or a single JSON object, and validates them against the input schema
+func toolArguments(t *client.Tool, words []string) (map[string]any, error) {
+	schema, err := schemaOf(t)
+	if err != nil {
+		return nil, err
+	}
+	args := map[string]any{}
+	if len(words) == 1 && strings.HasPrefix(words[0], "{") {
+		if err := json.Unmarshal([]byte(words[0]), &args); err != nil {
+			return nil, fmt.Errorf("arguments must be a JSON object: %w", err)
+		}
+		return args, schema.validate(args)
+	}
+	for _, w := range words {
+		k, v, ok := strings.Cut(w, "=")
+		if !ok {
+			return nil, fmt.Errorf("argument %q must be name=value", w)
+		}
+		if _, ok := args[k]; ok {
+			return nil, fmt.Errorf("argument %s is given twice", k)
+		}
+		val, err := coerce(schema.Properties[k].Type, v)
+		if err != nil {
+			return nil, fmt.Errorf("argument %s: %w", k, err)
+		}
+		args[k] = val
+	}
+	return args, schema.validate(args)
+}
+
+// validate checks arguments against the tool's input schema: their names,
+// JSON types and enums, and that the required ones are present
+func (schema *inputSchema) validate(args map[string]any) error {
+	var errs []string
+	names := make([]string, 0, len(args))
+	for k := range args {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		p, ok := schema.Properties[k]
+		if !ok {
+			// Unknown arguments are most likely typos, unless the
+			// schema allows them
+			if schema.AdditionalProperties == true {
+				continue
+			}
+			if _, ok := schema.AdditionalProperties.(map[string]any); ok {
+				continue
+			}
+			errs = append(errs, "unknown argument "+k)
+			continue
+		}
+		if !hasType(p.Type, args[k]) {
+			errs = append(errs, fmt.Sprintf("argument %s must be of type %v", k, p.Type))
+			continue
+		}
+		if len(p.Enum) > 0 && !slices.ContainsFunc(p.Enum, func(e any) bool { return sameJSON(e, args[k]) }) {
+			errs = append(errs, fmt.Sprintf("argument %s must be one of %v", k, p.Enum))
+		}
+	}
+	for _, k := range schema.Required {
+		if _, ok := args[k]; !ok {
+			errs = append(errs, "missing required argument "+k)
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// hasType reports whether v is of the JSON schema type, which may be a list
+// of types. Unknown types match anything.
+func hasType(typ any, v any) bool {
+	switch typ := typ.(type) {
+	case []any:
+		return slices.ContainsFunc(typ, func(t any) bool { return hasType(t, v) })
+	case string:
+		switch typ {
+		case "string":
+			_, ok := v.(string)
+			return ok
+		case "integer":
+			switch n := v.(type) {
+			case int64:
+				return true
+			case float64:
+				return n == float64(int64(n))
+			}
+			return false
+		case "number":
+			switch v.(type) {
+			case int64, float64:
+				return true
+			}
+			return false
+		case "boolean":
+			_, ok := v.(bool)
+			return ok
+		case "object":
+			_, ok := v.(map[string]any)
+			return ok
+		case "array":
+			_, ok := v.([]any)
+			return ok
+		case "null":
+			return v == nil
+		}
+	}
+	return true
+}
+
+func sameJSON(a, b any) bool {
+	x, err1 := json.Marshal(a)
+	y, err2 := json.Marshal(b)
+	return err1 == nil && err2 == nil && string(x) == string(y)
+}
+
+// describe prints the arguments of a tool
+func describe(t *client.Tool) error {
+	schema, err := schemaOf(t)
+	if err != nil {
+		return err
+	}
+	if t.Description != "" {
+		printText(t.Description)
+	}
+	names := make([]string, 0, len(schema.Properties))
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p := schema.Properties[name]
+		line := fmt.Sprintf("  %s (%v)", name, orDash(fmt.Sprint(p.Type)))
+		if slices.Contains(schema.Required, name) {
+			line += " required"
+		}
+		if len(p.Enum) > 0 {
+			line += fmt.Sprintf(" one of %v", p.Enum)
+		}
+		if p.Description != "" {
+			line += ": " + firstLine(p.Description)
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// splitWords splits a line at spaces, keeping the spaces inside single or
+// double quotes and after a backslash. A word starting with "{" is a JSON
+// object, and takes the rest of the line as it is.
+func splitWords(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord, quote, escaped := false, rune(0), false
+	for i, c := range line {
+		switch {
+		case !inWord && c == '{':
+			return append(words, strings.TrimSpace(line[i:])), nil
+		case escaped:
+			word.WriteRune(c)
+			escaped = false
+		case c == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(c)
+		case c == '"' || c == '\'':
+			quote, inWord = c, true
+		case c == ' ' || c == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, errors.New("unterminated quote or escape")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
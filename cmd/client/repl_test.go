@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/client"
+)
+
+func TestSplitWords(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    []string
+		wantErr bool
+	}{
+		{line: "", want: nil},
+		{line: "  call  hello\tname=Ann ", want: []string{"call", "hello", "name=Ann"}},
+		{line: `call hello "name=Ann Lee"`, want: []string{"call", "hello", "name=Ann Lee"}},
+		{line: `call hello name='Ann "A" Lee'`, want: []string{"call", "hello", `name=Ann "A" Lee`}},
+		{line: `call hello name=Ann\ Lee`, want: []string{"call", "hello", "name=Ann Lee"}},
+		{line: `call hello name='C:\temp'`, want: []string{"call", "hello", `name=C:\temp`}},
+		{line: `call hello {"name": "Ann Lee"} `, want: []string{"call", "hello", `{"name": "Ann Lee"}`}},
+		{line: `call hello "name=Ann`, wantErr: true},
+		{line: `call hello name=Ann\`, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := splitWords(tt.line)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("splitWords(%q): expected an error, got %q", tt.line, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("splitWords(%q): %v", tt.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitWords(%q): expected %q, got %q", tt.line, tt.want, got)
+		}
+	}
+}
+
+func TestToolArguments(t *testing.T) {
+	tool := &client.Tool{Name: "forecast", InputSchema: json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"city": {"type": "string"},
+			"days": {"type": "integer"},
+			"units": {"type": "string", "enum": ["metric", "imperial"]}
+		},
+		"required": ["city"]
+	}`)}
+	open := &client.Tool{Name: "open", InputSchema: json.RawMessage(`{"type":"object","additionalProperties":true}`)}
+
+	tests := []struct {
+		name  string
+		tool  *client.Tool
+		words []string
+		want  map[string]any
+		err   string
+	}{
+		{
+			name:  "name=value",
+			tool:  tool,
+			words: []string{"city=Oslo", "days=3", "units=metric"},
+			want:  map[string]any{"city": "Oslo", "days": int64(3), "units": "metric"},
+		},
+		{
+			name:  "JSON object",
+			tool:  tool,
+			words: []string{`{"city": "Oslo", "days": 3}`},
+			want:  map[string]any{"city": "Oslo", "days": 3.0},
+		},
+		{
+			name:  "additional properties",
+			tool:  open,
+			words: []string{"anything=1"},
+			want:  map[string]any{"anything": 1.0},
+		},
+		{name: "not name=value", tool: tool, words: []string{"Oslo"}, err: `argument "Oslo" must be name=value`},
+		{name: "given twice", tool: tool, words: []string{"city=Oslo", "city=Bergen"}, err: "argument city is given twice"},
+		{name: "not converted", tool: tool, words: []string{"city=Oslo", "days=many"}, err: "argument days: "},
+		{name: "wrong type", tool: tool, words: []string{`{"city": "Oslo", "days": 1.5}`}, err: "argument days must be of type integer"},
+		{name: "not in enum", tool: tool, words: []string{"city=Oslo", "units=kelvin"}, err: "argument units must be one of [metric imperial]"},
+		{name: "unknown", tool: tool, words: []string{"city=Oslo", "cty=Oslo"}, err: "unknown argument cty"},
+		{name: "missing", tool: tool, words: []string{"days=3"}, err: "missing required argument city"},
+		{name: "not a JSON object", tool: tool, words: []string{`{"city":`}, err: "arguments must be a JSON object"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := toolArguments(tt.tool, tt.words)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected an error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %#v, got %#v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/message"
+	pb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/jsonrpc"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/transcript"
+)
+
+type replayCmd struct {
+	Transcript string        `arg:"" type:"existingfile" help:"JSON-lines transcript recorded by a server or the client"`
+	Session    string        `help:"Session to replay; defaults to the first session of the transcript"`
+	Ignore     []string      `help:"JSON paths to leave out of the comparison, e.g. $.result.serverInfo.version"`
+	Timeout    time.Duration `default:"30s" help:"Time to wait for each response"`
+}
+
+// Run sends the messages the client sent in the recorded session, in their
+// recorded order. Before sending a message, it waits for the responses which
+// were recorded before it, so that the server sees the same interleaving.
+func (c *replayCmd) Run(g *Globals) error {
+	entries, err := transcript.ReadFile(c.Transcript)
+	if err != nil {
+		return err
+	}
+	sessionID := c.Session
+	if sessionID == "" {
+		if ids := transcript.Sessions(entries); len(ids) > 0 {
+			sessionID = ids[0]
+		}
+	}
+
+	conn, err := g.dial()
+	if err != nil {
+		return fmt.Errorf("did not connect: %w", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := pb.NewJSONRPCServiceClient(conn).Transport(ctx)
+	if err != nil {
+		return fmt.Errorf("could not open stream: %w", err)
+	}
+
+	received := make(chan *pb.GenericJSONRPCMessage)
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			m, err := stream.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			received <- m
+		}
+	}()
+
+	r := &replayer{received: received, recvErr: recvErr, responses: map[string]*pb.GenericJSONRPCMessage{}, timeout: c.Timeout}
+	sent := map[string]*pb.GenericJSONRPCMessage{}
+	var compared, failed int
+	for _, e := range entries {
+		if e.SessionID != sessionID {
+			continue
+		}
+		m, err := e.Decode()
+		if err != nil {
+			return err
+		}
+
+		switch e.Direction {
+		case transcript.DirectionToServer:
+			if message.IsRequest(m) {
+				sent[formatID(m.TypedId)] = m
+			}
+			if err := stream.Send(m); err != nil {
+				return fmt.Errorf("send: %w", err)
+			}
+
+		case transcript.DirectionToClient:
+			if m.Method != "" || m.TypedId == nil {
+				// Notifications and requests of the server are not compared
+				continue
+			}
+			key := formatID(m.TypedId)
+			req, ok := sent[key]
+			if !ok {
+				continue
+			}
+			delete(sent, key)
+			compared++
+
+			actual, err := r.wait(key)
+			if err != nil {
+				failed++
+				fmt.Printf("FAIL  %s %s\n      %v\n", key, req.Method, err)
+				continue
+			}
+			diffs, err := transcript.Diff(m, actual, c.Ignore...)
+			if err != nil {
+				return err
+			}
+			if len(diffs) == 0 {
+				fmt.Printf("ok    %s %s\n", key, req.Method)
+				continue
+			}
+			failed++
+			fmt.Printf("FAIL  %s %s\n", key, req.Method)
+			for _, d := range diffs {
+				fmt.Printf("      %s\n", d)
+			}
+		}
+	}
+	stream.CloseSend()
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d responses differ from the transcript", failed, compared)
+	}
+	fmt.Printf("%d responses match the transcript\n", compared)
+	return nil
+}
+
+// replayer collects the responses received during a replay
+type replayer struct {
+	received  <-chan *pb.GenericJSONRPCMessage
+	recvErr   <-chan error
+	responses map[string]*pb.GenericJSONRPCMessage
+	timeout   time.Duration
+	closed    error
+}
+
+// wait returns the response to the request with the given ID
+func (r *replayer) wait(key string) (*pb.GenericJSONRPCMessage, error) {
+	timer := time.NewTimer(r.timeout)
+	defer timer.Stop()
+	for {
+		if m, ok := r.responses[key]; ok {
+			delete(r.responses, key)
+			return m, nil
+		}
+		if r.closed != nil {
+			return nil, r.closed
+		}
+		select {
+		case m := <-r.received:
+			if m.Method == "" && m.TypedId != nil {
+				r.responses[formatID(m.TypedId)] = m
+			}
+		case err := <-r.recvErr:
+			r.closed = fmt.Errorf("stream closed before the response arrived: %w", err)
+		case <-timer.C:
+			return nil, fmt.Errorf("no response within %s", r.timeout)
+		}
+	}
+}
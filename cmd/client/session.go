@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/client"
+	grpc "google.golang.org/grpc"
+)
+
+// clientName identifies the client in the initialize handshake
+const clientName = "mcp-grpc-client"
+
+// mcpSession is an MCP session on a connection of its own, for the
+// subcommands and the REPL. The session is pinned to the replica its stream
+// was balanced to.
+type mcpSession struct {
+	*client.Client
+	conn   *grpc.ClientConn
+	notify func(client.Notification)
+	retry  *client.RetryPolicy
+}
+
+// openSession connects to the server and performs the initialize handshake.
+// The stream ends with ctx. Notifications are passed to notify, or log
+// messages written to stderr if it is nil. With a retry policy, broken
+// streams are replaced and idempotent requests sent again.
+func openSession(ctx context.Context, g *Globals, notify func(client.Notification), retry *client.RetryPolicy) (*mcpSession, error) {
+	conn, err := g.dial()
+	if err != nil {
+		return nil, fmt.Errorf("did not connect: %w", err)
+	}
+	if notify == nil {
+		notify = logNotification
+	}
+	s := &mcpSession{conn: conn, notify: notify, retry: retry}
+	if err := s.start(ctx, ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// reopen starts a new session on the connection of an ended one, which the
+// balancer puts on a reachable replica. The new stream ends with ctx, and
+// its handshake must complete within timeout.
+func (s *mcpSession) reopen(ctx context.Context, timeout time.Duration) (*mcpSession, error) {
+	initCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	next := &mcpSession{conn: s.conn, notify: s.notify, retry: s.retry}
+	if err := next.start(ctx, initCtx); err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+// start opens a stream ending with ctx, and initializes the session on it
+// within initCtx
+func (s *mcpSession) start(ctx, initCtx context.Context) error {
+	opts := []client.Option{client.WithClientInfo(clientName, "dev"), client.WithNotificationHandler(s.notify)}
+	if s.retry != nil {
+		opts = append(opts, client.WithRetry(s.retry))
+	}
+	c, err := client.New(ctx, s.conn, opts...)
+	if err != nil {
+		return fmt.Errorf("could not open stream: %w", err)
+	}
+	if _, err := c.Initialize(initCtx); err != nil {
+		c.Close()
+		return fmt.Errorf("initialize: %w", err)
+	}
+	s.Client = c
+	return nil
+}
+
+// retryPolicy returns the policy of the retry flags, or nil if retries are
+// disabled
+func (g *Globals) retryPolicy() *client.RetryPolicy {
+	if g.RetryAttempts <= 1 {
+		return nil
+	}
+	return &client.RetryPolicy{
+		MaxAttempts:    g.RetryAttempts,
+		InitialBackoff: g.RetryBackoff,
+		MaxBackoff:     g.RetryMaxBackoff,
+		Budget:         g.RetryBudget,
+		Tools:          g.RetryTool,
+	}
+}
+
+func (s *mcpSession) close() {
+	s.Close()
+	s.conn.Close()
+}
+
+// backend returns the address of the replica the session is pinned to
+func (s *mcpSession) backend() string {
+	if addr := s.Peer(); addr != nil {
+		return addr.String()
+	}
+	return "unknown"
+}
+
+// logNotification writes the log messages of the server to stderr
+func logNotification(n client.Notification) {
+	if n.Method == "notifications/message" {
+		fmt.Fprintf(os.Stderr, "Server log: %s\n", n.Params)
+	}
+}
@@ -0,0 +1,310 @@
+// Package admin implements the MCPAdmin gRPC service, which lets operators
+// inspect the live sessions of a server and cancel stuck requests or
+// terminate sessions.
+package admin
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/message"
+	adminpb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/admin"
+	pb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/jsonrpc"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/session"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ErrorCode is the JSON-RPC error code of requests cancelled by an operator
+const ErrorCode = message.ErrorCodeCancelled
+
+// Admin tracks the sessions of the server transports and serves them through
+// the MCPAdmin service
+type Admin struct {
+	adminpb.UnimplementedMCPAdminServer
+	authorize func(ctx context.Context) error
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+type Option func(*Admin)
+
+// WithAuthorizer checks every admin call, e.g. with
+// jwtauth.Authenticator.RequireScope. The returned error should be a gRPC
+// status error. Without an authorizer every admin call is denied.
+func WithAuthorizer(fn func(ctx context.Context) error) Option {
+	return func(a *Admin) {
+		a.authorize = fn
+	}
+}
+
+// New creates an Admin
+func New(opts ...Option) *Admin {
+	a := &Admin{sessions: make(map[string]*Session)}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Session is the admin view of a single Transport stream
+type Session struct {
+	admin     *Admin
+	info      *session.Info
+	terminate func(reason string)
+
+	mu              sync.Mutex
+	clientInfo      *adminpb.ClientInfo
+	protocolVersion string
+	received, sent  uint64
+	inflight        map[string]*request
+}
+
+type request struct {
+	id     *pb.ID
+	method string
+	tool   string
+	start  time.Time
+	cancel func(reason string)
+}
+
+// Open starts tracking a session. terminate is called to end its stream. It
+// returns nil for a nil Admin.
+func (a *Admin) Open(info *session.Info, terminate func(reason string)) *Session {
+	if a == nil {
+		return nil
+	}
+	s := &Session{
+		admin:     a,
+		info:      info,
+		terminate: terminate,
+		inflight:  make(map[string]*request),
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.sessions[info.ID] = s
+	return s
+}
+
+// Close stops tracking the session
+func (s *Session) Close() {
+	if s == nil {
+		return
+	}
+	s.admin.mu.Lock()
+	defer s.admin.mu.Unlock()
+	delete(s.admin.sessions, s.info.ID)
+}
+
+// Received counts a message received from the client and records the client
+// info of initialize requests
+func (s *Session) Received(m *pb.GenericJSONRPCMessage) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.received++
+	if m.Method == message.MethodInitialize {
+		params := m.GetParams().GetFields()
+		info := params["clientInfo"].GetStructValue().GetFields()
+		s.clientInfo = &adminpb.ClientInfo{
+			Name:    info["name"].GetStringValue(),
+			Version: info["version"].GetStringValue(),
+		}
+		s.protocolVersion = params["protocolVersion"].GetStringValue()
+	}
+}
+
+// Start records a request as in-flight until its response is sent. cancel
+// answers the request with an error and cancels its handler.
+func (s *Session) Start(m *pb.GenericJSONRPCMessage, cancel func(reason string)) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inflight[message.IDKey(m.TypedId)] = &request{
+		id:     m.TypedId,
+		method: m.Method,
+		tool:   message.ToolName(m),
+		start:  time.Now(),
+		cancel: cancel,
+	}
+}
+
+// Sent counts a message sent to the client. Responses end their request,
+// and the response to initialize carries the negotiated protocol version.
+func (s *Session) Sent(m *pb.GenericJSONRPCMessage, method string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent++
+	if m.Method != "" || m.TypedId == nil {
+		return
+	}
+	delete(s.inflight, message.IDKey(m.TypedId))
+	if v := m.GetResult().GetFields()["protocolVersion"].GetStringValue(); method == message.MethodInitialize && v != "" {
+		s.protocolVersion = v
+	}
+}
+
+func (s *Session) snapshot() *adminpb.Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := &adminpb.Session{
+		Id:               s.info.ID,
+		Principal:        s.info.Principal,
+		Peer:             s.info.Peer,
+		StartedAt:        timestamppb.New(s.info.StartedAt),
+		ClientInfo:       s.clientInfo,
+		ProtocolVersion:  s.protocolVersion,
+		MessagesReceived: s.received,
+		MessagesSent:     s.sent,
+	}
+	for _, r := range s.inflight {
+		out.InflightRequests = append(out.InflightRequests, &adminpb.InflightRequest{
+			Id:        r.id,
+			Method:    r.method,
+			Tool:      r.tool,
+			StartedAt: timestamppb.New(r.start),
+		})
+	}
+	sort.Slice(out.InflightRequests, func(i, j int) bool {
+		return out.InflightRequests[i].StartedAt.AsTime().Before(out.InflightRequests[j].StartedAt.AsTime())
+	})
+	return out
+}
+
+func (a *Admin) ListSessions(ctx context.Context, _ *adminpb.ListSessionsRequest) (*adminpb.ListSessionsResponse, error) {
+	if err := a.check(ctx); err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	sessions := make([]*Session, 0, len(a.sessions))
+	for _, s := range a.sessions {
+		sessions = append(sessions, s)
+	}
+	a.mu.Unlock()
+
+	resp := &adminpb.ListSessionsResponse{}
+	for _, s := range sessions {
+		resp.Sessions = append(resp.Sessions, s.snapshot())
+	}
+	sort.Slice(resp.Sessions, func(i, j int) bool {
+		return resp.Sessions[i].StartedAt.AsTime().Before(resp.Sessions[j].StartedAt.AsTime())
+	})
+	return resp, nil
+}
+
+func (a *Admin) CancelRequest(ctx context.Context, req *adminpb.CancelRequestRequest) (*adminpb.CancelRequestResponse, error) {
+	if err := a.check(ctx); err != nil {
+		return nil, err
+	}
+	s, err := a.session(req.SessionId)
+	if err != nil {
+		return nil, err
+	}
+	if req.RequestId == nil {
+		return nil, status.Error(codes.InvalidArgument, "request_id is required")
+	}
+
+	s.mu.Lock()
+	key := message.IDKey(req.RequestId)
+	r := s.inflight[key]
+	delete(s.inflight, key)
+	s.mu.Unlock()
+	if r == nil {
+		return nil, status.Errorf(codes.NotFound, "no in-flight request %s in session %s", key, req.SessionId)
+	}
+
+	r.cancel(reason(req.Reason, "request cancelled by an administrator"))
+	return &adminpb.CancelRequestResponse{}, nil
+}
+
+func (a *Admin) TerminateSession(ctx context.Context, req *adminpb.TerminateSessionRequest) (*adminpb.TerminateSessionResponse, error) {
+	if err := a.check(ctx); err != nil {
+		return nil, err
+	}
+	s, err := a.session(req.SessionId)
+	if err != nil {
+		return nil, err
+	}
+	s.terminate(reason(req.Reason, "session terminated by an administrator"))
+	return &adminpb.TerminateSessionResponse{}, nil
+}
+
+func (a *Admin) check(ctx context.Context) error {
+	if a.authorize == nil {
+		return status.Error(codes.PermissionDenied, "the admin service has no authorizer")
+	}
+	err := a.authorize(ctx)
+	if _, ok := status.FromError(err); !ok {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	return err
+}
+
+// Authenticated returns the service with every call authenticated by fn
+// before it is authorized, e.g. by the authenticator of the transport. fn
+// returns the context passed on to the call.
+func (a *Admin) Authenticated(fn func(ctx context.Context) (context.Context, error)) adminpb.MCPAdminServer {
+	return &authenticated{Admin: a, authenticate: fn}
+}
+
+type authenticated struct {
+	*Admin
+	authenticate func(ctx context.Context) (context.Context, error)
+}
+
+func (a *authenticated) ListSessions(ctx context.Context, req *adminpb.ListSessionsRequest) (*adminpb.ListSessionsResponse, error) {
+	ctx, err := a.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return a.Admin.ListSessions(ctx, req)
+}
+
+func (a *authenticated) CancelRequest(ctx context.Context, req *adminpb.CancelRequestRequest) (*adminpb.CancelRequestResponse, error) {
+	ctx, err := a.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return a.Admin.CancelRequest(ctx, req)
+}
+
+func (a *authenticated) TerminateSession(ctx context.Context, req *adminpb.TerminateSessionRequest) (*adminpb.TerminateSessionResponse, error) {
+	ctx, err := a.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return a.Admin.TerminateSession(ctx, req)
+}
+
+func (a *Admin) session(id string) (*Session, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	s, ok := a.sessions[id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "no session %s", id)
+	}
+	return s, nil
+}
+
+// CancelledError returns the error response sent for a cancelled request
+func CancelledError(id *pb.ID, reason string) *pb.GenericJSONRPCMessage {
+	return message.NewError(id, ErrorCode, reason, nil)
+}
+
+func reason(r, fallback string) string {
+	if r == "" {
+		return fallback
+	}
+	return r
+}
@@ -0,0 +1,134 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	adminpb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/admin"
+	pb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/jsonrpc"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/session"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// allowAll authorizes every admin call
+var allowAll = WithAuthorizer(func(context.Context) error { return nil })
+
+func numID(n int64) *pb.ID { return &pb.ID{Kind: &pb.ID_Num{Num: n}} }
+
+func mustStruct(t *testing.T, m map[string]any) *structpb.Struct {
+	t.Helper()
+	s, err := structpb.NewStruct(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSession_Lifecycle(t *testing.T) {
+	a := New(allowAll)
+	s := a.Open(&session.Info{ID: "s1", Principal: "alice"}, func(string) {})
+
+	s.Received(&pb.GenericJSONRPCMessage{TypedId: numID(1), Method: "initialize", Params: mustStruct(t, map[string]any{
+		"protocolVersion": "2025-06-18",
+		"clientInfo":      map[string]any{"name": "ide", "version": "1.2.3"},
+	})})
+	s.Sent(&pb.GenericJSONRPCMessage{TypedId: numID(1), Result: mustStruct(t, map[string]any{"protocolVersion": "2025-03-26"})}, "initialize")
+
+	call := &pb.GenericJSONRPCMessage{TypedId: numID(2), Method: "tools/call", Params: mustStruct(t, map[string]any{"name": "slow"})}
+	s.Received(call)
+	var cancelled string
+	s.Start(call, func(reason string) { cancelled = reason })
+
+	resp, err := a.ListSessions(context.Background(), &adminpb.ListSessionsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := resp.Sessions[0]
+	if got.Principal != "alice" || got.ClientInfo.GetName() != "ide" || got.ProtocolVersion != "2025-03-26" {
+		t.Errorf("unexpected session: %v", got)
+	}
+	if got.MessagesReceived != 2 || got.MessagesSent != 1 || len(got.InflightRequests) != 1 || got.InflightRequests[0].Tool != "slow" {
+		t.Errorf("unexpected counts or in-flight requests: %v", got)
+	}
+
+	if _, err := a.CancelRequest(context.Background(), &adminpb.CancelRequestRequest{SessionId: "s1", RequestId: numID(2)}); err != nil {
+		t.Fatal(err)
+	}
+	if cancelled == "" {
+		t.Error("expected the request to be cancelled")
+	}
+	_, err = a.CancelRequest(context.Background(), &adminpb.CancelRequestRequest{SessionId: "s1", RequestId: numID(2)})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound for an answered request, got %v", err)
+	}
+
+	s.Close()
+	_, err = a.TerminateSession(context.Background(), &adminpb.TerminateSessionRequest{SessionId: "s1"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound for a closed session, got %v", err)
+	}
+}
+
+func TestTerminateSession(t *testing.T) {
+	a := New(allowAll)
+	var reason string
+	a.Open(&session.Info{ID: "s1"}, func(r string) { reason = r })
+
+	if _, err := a.TerminateSession(context.Background(), &adminpb.TerminateSessionRequest{SessionId: "s1", Reason: "maintenance"}); err != nil {
+		t.Fatal(err)
+	}
+	if reason != "maintenance" {
+		t.Errorf("expected the session to be terminated with the given reason, got %q", reason)
+	}
+}
+
+func TestAuthorizer(t *testing.T) {
+	a := New(WithAuthorizer(func(context.Context) error { return errors.New("nope") }))
+	_, err := a.ListSessions(context.Background(), &adminpb.ListSessionsRequest{})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("expected PermissionDenied, got %v", err)
+	}
+}
+
+func TestNoAuthorizer(t *testing.T) {
+	a := New()
+	a.Open(&session.Info{ID: "s1"}, func(string) { t.Error("expected the session not to be terminated") })
+	if _, err := a.TerminateSession(context.Background(), &adminpb.TerminateSessionRequest{SessionId: "s1"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("expected PermissionDenied without an authorizer, got %v", err)
+	}
+}
+
+func TestAuthenticated(t *testing.T) {
+	type key struct{}
+	a := New(WithAuthorizer(func(ctx context.Context) error {
+		if ctx.Value(key{}) == nil {
+			return status.Error(codes.PermissionDenied, "not authenticated")
+		}
+		return nil
+	}))
+	srv := a.Authenticated(func(ctx context.Context) (context.Context, error) {
+		return context.WithValue(ctx, key{}, "alice"), nil
+	})
+	if _, err := srv.ListSessions(context.Background(), &adminpb.ListSessionsRequest{}); err != nil {
+		t.Errorf("expected the authenticated call to be authorized, got %v", err)
+	}
+
+	srv = a.Authenticated(func(ctx context.Context) (context.Context, error) {
+		return nil, status.Error(codes.Unauthenticated, "missing authorization metadata")
+	})
+	if _, err := srv.ListSessions(context.Background(), &adminpb.ListSessionsRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected Unauthenticated, got %v", err)
+	}
+}
+
+func TestNilAdmin(t *testing.T) {
+	var a *Admin
+	s := a.Open(&session.Info{ID: "s1"}, nil)
+	s.Received(&pb.GenericJSONRPCMessage{})
+	s.Start(&pb.GenericJSONRPCMessage{}, nil)
+	s.Sent(&pb.GenericJSONRPCMessage{}, "")
+	s.Close()
+}
@@ -0,0 +1,221 @@
+// Package audit records an audit trail of the tool, resource and prompt
+// requests handled by the gRPC server transports.
+package audit
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/message"
+	pb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/jsonrpc"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/session"
+)
+
+// Result statuses of an audited request
+const (
+	StatusOK        = "ok"
+	StatusError     = "error"
+	StatusToolError = "tool_error"
+	StatusAborted   = "aborted"
+)
+
+// Event is a single audited request
+type Event struct {
+	Time      time.Time `json:"time"`
+	Principal string    `json:"principal,omitempty"`
+	SessionID string    `json:"sessionId"`
+	Peer      string    `json:"peer,omitempty"`
+	Method    string    `json:"method"`
+	Tool      string    `json:"tool,omitempty"`
+	// Resource is the scheme and host of a read resource's URI, whose other
+	// parts may identify a user. ResourceHash identifies the full URI.
+	Resource      string  `json:"resource,omitempty"`
+	ResourceHash  string  `json:"resourceHash,omitempty"`
+	Prompt        string  `json:"prompt,omitempty"`
+	ArgumentsHash string  `json:"argumentsHash,omitempty"`
+	Arguments     any     `json:"arguments,omitempty"`
+	Status        string  `json:"status"`
+	ErrorCode     int32   `json:"errorCode,omitempty"`
+	DurationMs    float64 `json:"durationMs"`
+}
+
+// Sink receives audit events
+type Sink interface {
+	Write(Event) error
+}
+
+// Logger turns requests and their responses into audit events.
+// A nil *Logger audits nothing.
+type Logger struct {
+	sink      Sink
+	redact    []path
+	withCopy  bool
+	hashKey   []byte
+	onError   func(error)
+	auditable map[string]bool
+}
+
+type Option func(*Logger)
+
+// WithRedactedArguments includes a copy of the arguments in every event,
+// with the values at the given JSON paths replaced. Paths are relative to
+// the arguments object, e.g. "$.password", "$.auth.token" or "$.items[*].key".
+func WithRedactedArguments(paths ...string) Option {
+	return func(l *Logger) {
+		l.withCopy = true
+		for _, p := range paths {
+			l.redact = append(l.redact, parsePath(p))
+		}
+	}
+}
+
+// WithHashKey sets the key of the HMAC-SHA256 hashes of arguments and
+// resource URIs. Events hashed with the same key can be correlated, e.g.
+// across restarts or replicas. Keep the key secret: with it, low-entropy
+// arguments can be guessed from their hash.
+func WithHashKey(key []byte) Option {
+	return func(l *Logger) {
+		l.hashKey = key
+	}
+}
+
+// WithErrorHandler sets the handler for events which could not be written
+func WithErrorHandler(handler func(error)) Option {
+	return func(l *Logger) {
+		l.onError = handler
+	}
+}
+
+// New creates an audit Logger writing to the sink. By default events carry
+// an HMAC-SHA256 hash of the arguments but not the arguments themselves,
+// keyed with a random key unless WithHashKey is given.
+func New(sink Sink, opts ...Option) *Logger {
+	l := &Logger{
+		sink: sink,
+		onError: func(err error) {
+			fmt.Fprintf(os.Stderr, "failed to write audit event: %v\n", err)
+		},
+		auditable: map[string]bool{
+			message.MethodToolsCall:     true,
+			message.MethodResourcesRead: true,
+			message.MethodPromptsGet:    true,
+		},
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	if len(l.hashKey) == 0 {
+		l.hashKey = make([]byte, 32)
+		rand.Read(l.hashKey)
+	}
+	return l
+}
+
+// Record is an audit event waiting for the response to its request
+type Record struct {
+	logger *Logger
+	start  time.Time
+	event  Event
+}
+
+// Start begins auditing a request. It returns nil for requests which are not
+// audited; a nil *Record can be finished safely.
+func (l *Logger) Start(info *session.Info, req *pb.GenericJSONRPCMessage) *Record {
+	if l == nil || !message.IsRequest(req) || !l.auditable[req.Method] {
+		return nil
+	}
+
+	r := &Record{
+		logger: l,
+		start:  time.Now(),
+		event: Event{
+			Method: req.Method,
+		},
+	}
+	if info != nil {
+		r.event.Principal = info.Principal
+		r.event.SessionID = info.ID
+		r.event.Peer = info.Peer
+	}
+
+	fields := req.GetParams().GetFields()
+	var args any
+	switch req.Method {
+	case message.MethodToolsCall:
+		r.event.Tool = fields["name"].GetStringValue()
+		args = fields["arguments"].AsInterface()
+	case message.MethodPromptsGet:
+		r.event.Prompt = fields["name"].GetStringValue()
+		args = fields["arguments"].AsInterface()
+	case message.MethodResourcesRead:
+		uri := fields["uri"].GetStringValue()
+		r.event.Resource = redactURI(uri)
+		r.event.ResourceHash = l.hash([]byte(uri))
+	}
+
+	if args != nil {
+		if b, err := json.Marshal(args); err == nil {
+			r.event.ArgumentsHash = l.hash(b)
+		}
+		if l.withCopy {
+			r.event.Arguments = redact(args, l.redact)
+		}
+	}
+	return r
+}
+
+func (l *Logger) hash(b []byte) string {
+	mac := hmac.New(sha256.New, l.hashKey)
+	mac.Write(b)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// redactURI keeps the scheme and host of a URI
+func redactURI(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme == "" {
+		return Redacted
+	}
+	return (&url.URL{Scheme: u.Scheme, Host: u.Host}).String()
+}
+
+// Finish completes the event with the response sent for the request
+func (r *Record) Finish(resp *pb.GenericJSONRPCMessage) {
+	if r == nil {
+		return
+	}
+	switch {
+	case resp.GetError() != nil:
+		r.event.Status = StatusError
+		r.event.ErrorCode = resp.GetError().GetCode()
+	case resp.GetResult().GetFields()["isError"].GetBoolValue():
+		r.event.Status = StatusToolError
+	default:
+		r.event.Status = StatusOK
+	}
+	r.write()
+}
+
+// Abort completes the event for a request which was never answered
+func (r *Record) Abort() {
+	if r == nil {
+		return
+	}
+	r.event.Status = StatusAborted
+	r.write()
+}
+
+func (r *Record) write() {
+	r.event.Time = r.start.UTC()
+	r.event.DurationMs = float64(time.Since(r.start).Microseconds()) / 1000
+	if err := r.logger.sink.Write(r.event); err != nil && r.logger.onError != nil {
+		r.logger.onError(err)
+	}
+}
@@ -0,0 +1,154 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	pb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/jsonrpc"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/session"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+type memorySink struct {
+	events []Event
+}
+
+func (s *memorySink) Write(e Event) error {
+	s.events = append(s.events, e)
+	return nil
+}
+
+func toolCall(t *testing.T, args map[string]any) *pb.GenericJSONRPCMessage {
+	t.Helper()
+	params, err := structpb.NewStruct(map[string]any{"name": "deploy", "arguments": args})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &pb.GenericJSONRPCMessage{
+		Jsonrpc: "2.0",
+		TypedId: &pb.ID{Kind: &pb.ID_Num{Num: 7}},
+		Method:  "tools/call",
+		Params:  params,
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	tests := map[string]path{
+		"$.password":          {"password"},
+		"$.auth.token":        {"auth", "token"},
+		"$.items[*].secret":   {"items", "*", "secret"},
+		"$.items[0]":          {"items", "0"},
+		"$['key.with.dots']":  {"key.with.dots"},
+		"nested.without.root": {"nested", "without", "root"},
+	}
+	for in, want := range tests {
+		if got := parsePath(in); !reflect.DeepEqual(got, want) {
+			t.Errorf("parsePath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestLogger_RedactsArguments(t *testing.T) {
+	sink := &memorySink{}
+	l := New(sink, WithRedactedArguments("$.token", "$.targets[*].password"))
+
+	info := &session.Info{ID: "s1", Principal: "alice", Peer: "10.0.0.1:1234"}
+	rec := l.Start(info, toolCall(t, map[string]any{
+		"token":   "secret",
+		"region":  "eu",
+		"targets": []any{map[string]any{"host": "a", "password": "p1"}},
+	}))
+	rec.Finish(&pb.GenericJSONRPCMessage{Result: &structpb.Struct{}})
+
+	if len(sink.events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(sink.events))
+	}
+	e := sink.events[0]
+	if e.Tool != "deploy" || e.Principal != "alice" || e.SessionID != "s1" || e.Status != StatusOK {
+		t.Errorf("unexpected event: %+v", e)
+	}
+	if e.ArgumentsHash == "" {
+		t.Error("expected an arguments hash")
+	}
+
+	got, _ := json.Marshal(e.Arguments)
+	want := `{"region":"eu","targets":[{"host":"a","password":"[REDACTED]"}],"token":"[REDACTED]"}`
+	if string(got) != want {
+		t.Errorf("unexpected redacted arguments:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestLogger_HashOnlyByDefault(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(NewJSONLinesSink(&buf))
+
+	l.Start(&session.Info{ID: "s1"}, toolCall(t, map[string]any{"token": "secret"})).
+		Finish(&pb.GenericJSONRPCMessage{Error: &pb.JSONRPCError{Code: -32602}})
+
+	if bytes.Contains(buf.Bytes(), []byte("secret")) {
+		t.Errorf("arguments must not be logged by default: %s", buf.String())
+	}
+	var e Event
+	if err := json.Unmarshal(buf.Bytes(), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Status != StatusError || e.ErrorCode != -32602 {
+		t.Errorf("unexpected status: %+v", e)
+	}
+}
+
+func TestLogger_HashesWithKey(t *testing.T) {
+	args := map[string]any{"email": "alice@example.com"}
+	hash := func(opts ...Option) string {
+		sink := &memorySink{}
+		New(sink, opts...).Start(&session.Info{}, toolCall(t, args)).Abort()
+		return sink.events[0].ArgumentsHash
+	}
+
+	b, _ := json.Marshal(args)
+	plain := sha256.Sum256(b)
+	keyed := hash(WithHashKey([]byte("k1")))
+	if keyed == hex.EncodeToString(plain[:]) {
+		t.Error("expected the arguments hash to be keyed")
+	}
+	if hash(WithHashKey([]byte("k1"))) != keyed {
+		t.Error("expected the same key to give the same hash")
+	}
+	if hash(WithHashKey([]byte("k2"))) == keyed || hash() == keyed {
+		t.Error("expected other keys to give other hashes")
+	}
+}
+
+func TestLogger_RedactsResourceURIs(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(NewJSONLinesSink(&buf))
+	params, _ := structpb.NewStruct(map[string]any{"uri": "https://alice:pw@api.example.com/users/alice?token=secret"})
+	l.Start(&session.Info{ID: "s1"}, &pb.GenericJSONRPCMessage{
+		TypedId: &pb.ID{Kind: &pb.ID_Num{Num: 1}},
+		Method:  "resources/read",
+		Params:  params,
+	}).Abort()
+
+	var e Event
+	if err := json.Unmarshal(buf.Bytes(), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Resource != "https://api.example.com" || e.ResourceHash == "" {
+		t.Errorf("unexpected resource: %+v", e)
+	}
+	if bytes.Contains(buf.Bytes(), []byte("alice")) || bytes.Contains(buf.Bytes(), []byte("secret")) {
+		t.Errorf("the URI must not be logged in full: %s", buf.String())
+	}
+}
+
+func TestLogger_IgnoresUnauditedMethods(t *testing.T) {
+	l := New(&memorySink{})
+	msg := &pb.GenericJSONRPCMessage{TypedId: &pb.ID{Kind: &pb.ID_Num{Num: 1}}, Method: "tools/list"}
+	if rec := l.Start(&session.Info{}, msg); rec != nil {
+		t.Error("tools/list must not be audited")
+	}
+}
@@ -0,0 +1,48 @@
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+// JSONLinesSink writes every event as a single line of JSON
+type JSONLinesSink struct {
+	mu  sync.Mutex
+	w   io.Writer
+	enc *json.Encoder
+}
+
+// NewJSONLinesSink creates a sink writing to w
+func NewJSONLinesSink(w io.Writer) *JSONLinesSink {
+	return &JSONLinesSink{
+		w:   w,
+		enc: json.NewEncoder(w),
+	}
+}
+
+// NewJSONLinesFile creates a sink appending to the file at path
+func NewJSONLinesFile(path string) (*JSONLinesSink, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return NewJSONLinesSink(f), nil
+}
+
+func (s *JSONLinesSink) Write(e Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(e)
+}
+
+// Close closes the underlying writer if it is closable
+func (s *JSONLinesSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
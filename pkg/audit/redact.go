@@ -0,0 +1,82 @@
+package audit
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Redacted replaces the values matched by a redaction path
+const Redacted = "[REDACTED]"
+
+// path is a parsed JSON path; "*" matches any object key or array element
+type path []string
+
+// parsePath parses a simple JSON path such as "$.a.b", "$.list[*].secret",
+// "$.list[0]" or "$['key.with.dots']".
+func parsePath(p string) path {
+	p = strings.TrimPrefix(strings.TrimSpace(p), "$")
+
+	var segs path
+	for len(p) > 0 {
+		switch p[0] {
+		case '.':
+			p = p[1:]
+		case '[':
+			end := strings.IndexByte(p, ']')
+			if end < 0 {
+				return append(segs, p[1:])
+			}
+			segs = append(segs, strings.Trim(p[1:end], `'"`))
+			p = p[end+1:]
+		default:
+			end := strings.IndexAny(p, ".[")
+			if end < 0 {
+				end = len(p)
+			}
+			segs = append(segs, p[:end])
+			p = p[end:]
+		}
+	}
+	return segs
+}
+
+// redact replaces the values at the given paths. The value must be a fresh
+// copy, as produced by structpb's AsInterface, since it is modified in place.
+func redact(v any, paths []path) any {
+	for _, p := range paths {
+		if len(p) == 0 {
+			return Redacted
+		}
+		redactPath(v, p)
+	}
+	return v
+}
+
+func redactPath(v any, p path) {
+	seg, rest := p[0], p[1:]
+
+	switch node := v.(type) {
+	case map[string]any:
+		for k, child := range node {
+			if seg != "*" && seg != k {
+				continue
+			}
+			if len(rest) == 0 {
+				node[k] = Redacted
+			} else {
+				redactPath(child, rest)
+			}
+		}
+	case []any:
+		for i, child := range node {
+			if seg != "*" && seg != strconv.Itoa(i) {
+				continue
+			}
+			if len(rest) == 0 {
+				node[i] = Redacted
+			} else {
+				redactPath(child, rest)
+			}
+		}
+	}
+}
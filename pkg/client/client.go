@@ -0,0 +1,478 @@
+// Package client is an MCP client over the Transport stream of a
+// JSONRPCService, for services which call MCP servers over gRPC without
+// pulling in an MCP framework. It allocates request IDs, matches responses
+// to their requests, cancels requests whose context is done and passes the
+// notifications of the server to a callback.
+//
+//	c, err := client.New(ctx, conn)
+//	if _, err := c.Initialize(ctx); err != nil { ... }
+//	result, err := c.CallTool(ctx, "hello", map[string]any{"name": "Ann"})
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/codec"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/message"
+	pb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/jsonrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// ProtocolVersion is the MCP version asked for by Initialize
+const ProtocolVersion = "2025-03-26"
+
+// methodNotFound answers the requests of the server, which the client has
+// no handlers for
+const methodNotFound = -32601
+
+// Client is an MCP session. Its methods may be called concurrently.
+//
+// The session is a single stream, unless the client retries: with
+// WithRetry, a stream which broke with a transient error is replaced by a
+// new one on the next request, which is initialized again if Initialize
+// was called.
+type Client struct {
+	conn           grpc.ClientConnInterface
+	info           Implementation
+	callOpts       []grpc.CallOption
+	onNotification func(Notification)
+	retry          *RetryPolicy
+
+	// ctx is the context of the streams, which Close cancels
+	ctx    context.Context
+	cancel context.CancelFunc
+	// reconnectMu serializes replacing a broken stream
+	reconnectMu sync.Mutex
+
+	mu          sync.Mutex
+	stream      *stream
+	initialized bool
+	nextID      int64
+	calls       map[int64]chan *pb.GenericJSONRPCMessage
+	// done is closed with err set once the client has ended
+	done chan struct{}
+	err  error
+}
+
+// stream is a Transport stream of the client, which is one server session
+type stream struct {
+	pb.JSONRPCService_TransportClient
+	cancel context.CancelFunc
+	// sendMu serializes Send, as requests are sent concurrently
+	sendMu sync.Mutex
+	// done is closed with err set once the stream has ended
+	done chan struct{}
+	err  error
+}
+
+type Option func(*Client)
+
+// WithClientInfo sets the name and version of the client sent in the
+// initialize request
+func WithClientInfo(name, version string) Option {
+	return func(c *Client) {
+		c.info = Implementation{Name: name, Version: version}
+	}
+}
+
+// WithNotificationHandler calls fn with every notification from the server,
+// e.g. log messages, progress or resource updates. It is called from the
+// goroutine receiving the messages of the stream, so it must not block.
+func WithNotificationHandler(fn func(Notification)) Option {
+	return func(c *Client) {
+		c.onNotification = fn
+	}
+}
+
+// WithCallOpts sets the options of the Transport call, e.g. per-RPC
+// credentials or grpc.WaitForReady
+func WithCallOpts(opts ...grpc.CallOption) Option {
+	return func(c *Client) {
+		c.callOpts = opts
+	}
+}
+
+// WithRetry replaces streams which broke with a transient error, and sends
+// the requests the policy allows again on the new stream
+func WithRetry(policy *RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+// ErrClosed is returned by calls on a client after Close
+var ErrClosed = errors.New("client closed")
+
+// New opens a stream on conn. The client ends with ctx or Close. The
+// connection is owned by the caller, and is not closed by Close.
+func New(ctx context.Context, conn grpc.ClientConnInterface, opts ...Option) (*Client, error) {
+	c := &Client{
+		conn:  conn,
+		info:  Implementation{Name: "mcp-grpc-transport", Version: "dev"},
+		calls: map[int64]chan *pb.GenericJSONRPCMessage{},
+		done:  make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	st, err := c.open()
+	if err != nil {
+		c.cancel()
+		return nil, err
+	}
+	c.stream = st
+	go c.receive(st)
+	return c, nil
+}
+
+// open opens a stream, whose messages are to be received by receive
+func (c *Client) open() (*stream, error) {
+	ctx, cancel := context.WithCancel(c.ctx)
+	ts, err := pb.NewJSONRPCServiceClient(c.conn).Transport(ctx, c.callOpts...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &stream{JSONRPCService_TransportClient: ts, cancel: cancel, done: make(chan struct{})}, nil
+}
+
+// Initialize performs the initialize handshake, which must precede any
+// other request
+func (c *Client) Initialize(ctx context.Context) (*InitializeResult, error) {
+	st, err := c.session(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.handshake(ctx, st)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.initialized = true
+	c.mu.Unlock()
+	return result, nil
+}
+
+func (c *Client) handshake(ctx context.Context, st *stream) (*InitializeResult, error) {
+	req, err := newMessage(message.MethodInitialize, map[string]any{
+		"protocolVersion": ProtocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo":      c.info,
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.roundTrip(ctx, st, req)
+	if err != nil {
+		return nil, err
+	}
+	var result InitializeResult
+	if err := decodeResult(resp, &result); err != nil {
+		return nil, err
+	}
+	initialized, _ := newMessage("notifications/initialized", nil)
+	if err := c.send(st, initialized); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Close ends the client and its stream. Calls still waiting for their
+// response fail with ErrClosed.
+func (c *Client) Close() error {
+	if !c.fail(ErrClosed) {
+		return nil
+	}
+	st := c.current()
+	st.sendMu.Lock()
+	err := st.CloseSend()
+	st.sendMu.Unlock()
+	c.cancel()
+	return err
+}
+
+// Peer returns the address of the server the stream is connected to, which
+// every request of the session goes to, or nil if it is unknown
+func (c *Client) Peer() net.Addr {
+	if p, ok := peer.FromContext(c.current().Context()); ok {
+		return p.Addr
+	}
+	return nil
+}
+
+// Done is closed when the client has ended: after Close, once its context
+// is done, or when its stream broke and was not to be replaced
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns why the client ended, once it has
+func (c *Client) Err() error {
+	select {
+	case <-c.done:
+		return c.err
+	default:
+		return nil
+	}
+}
+
+// Call sends a request and decodes the result of its response into result,
+// unless it is nil. Params are encoded as JSON, and may be nil. An error
+// response is returned as an *Error. If ctx is done first, the server is
+// told with notifications/cancelled. With a retry policy, a request which
+// failed as its stream broke is sent again on a new stream if the policy
+// allows it, as is any request for which no new stream could be opened.
+func (c *Client) Call(ctx context.Context, method string, params, result any) error {
+	req, err := newMessage(method, params)
+	if err != nil {
+		return err
+	}
+	if c.retry != nil {
+		c.retry.Sent()
+	}
+	for attempt := 1; ; attempt++ {
+		st, err := c.session(ctx)
+		sent := err == nil
+		var resp *pb.GenericJSONRPCMessage
+		if sent {
+			resp, err = c.roundTrip(ctx, st, req)
+		}
+		if err == nil {
+			return decodeResult(resp, result)
+		}
+		if !c.retries(req, sent, attempt, err) {
+			return err
+		}
+		select {
+		case <-time.After(c.retry.Backoff(attempt)):
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.done:
+			return c.err
+		}
+	}
+}
+
+// retries reports whether a request which failed with err on the given
+// attempt is to be sent again
+func (c *Client) retries(req *pb.GenericJSONRPCMessage, sent bool, attempt int, err error) bool {
+	if c.retry == nil || !Transient(err) {
+		return false
+	}
+	if sent && !c.retry.Idempotent(req.Method, message.ToolName(req)) {
+		return false
+	}
+	return c.retry.Retry(attempt)
+}
+
+// roundTrip sends a request on st under a new ID and waits for its response
+func (c *Client) roundTrip(ctx context.Context, st *stream, req *pb.GenericJSONRPCMessage) (*pb.GenericJSONRPCMessage, error) {
+	c.mu.Lock()
+	c.nextID++
+	id := c.nextID
+	ch := make(chan *pb.GenericJSONRPCMessage, 1)
+	c.calls[id] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.calls, id)
+		c.mu.Unlock()
+	}()
+
+	req.TypedId = &pb.ID{Kind: &pb.ID_Num{Num: id}}
+	if err := c.send(st, req); err != nil {
+		return nil, err
+	}
+
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-st.done:
+		return nil, st.err
+	case <-c.done:
+		return nil, c.err
+	case <-ctx.Done():
+		// Let the server stop working on a request nobody waits for
+		c.send(st, message.NewCancelled(req.TypedId, ctx.Err().Error()))
+		return nil, ctx.Err()
+	}
+}
+
+// decodeResult decodes the result of a response into result, unless it is
+// nil, or returns its error as an *Error
+func decodeResult(resp *pb.GenericJSONRPCMessage, result any) error {
+	if e := resp.GetError(); e != nil {
+		return &Error{Code: int(e.Code), Message: e.Message, Data: codec.ErrorData(e)}
+	}
+	if result == nil {
+		return nil
+	}
+	b, err := codec.EncodeStruct(resp.Result)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, result)
+}
+
+// Notify sends a notification. Params are encoded as JSON, and may be nil.
+func (c *Client) Notify(method string, params any) error {
+	msg, err := newMessage(method, params)
+	if err != nil {
+		return err
+	}
+	return c.send(c.current(), msg)
+}
+
+func newMessage(method string, params any) (*pb.GenericJSONRPCMessage, error) {
+	msg := &pb.GenericJSONRPCMessage{Jsonrpc: codec.Version, Method: method}
+	var err error
+	if msg.Params, err = encodeParams(params); err != nil {
+		return nil, fmt.Errorf("%s: %w", method, err)
+	}
+	return msg, nil
+}
+
+func encodeParams(params any) (*structpb.Struct, error) {
+	b, err := json.Marshal(params)
+	if err != nil || string(b) == "null" {
+		return nil, err
+	}
+	return codec.DecodeStruct(b)
+}
+
+func (c *Client) current() *stream {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stream
+}
+
+// session returns the stream to send requests on. With a retry policy, a
+// stream which broke is replaced by a new one first.
+func (c *Client) session(ctx context.Context) (*stream, error) {
+	if err := c.Err(); err != nil {
+		return nil, err
+	}
+	st := c.current()
+	select {
+	case <-st.done:
+	default:
+		return st, nil
+	}
+	if c.retry == nil {
+		return nil, st.err
+	}
+	return c.reconnect(ctx, st)
+}
+
+// reconnect opens a stream in place of a broken one, and initializes it if
+// the client was. The client ends if that fails with an error which is not
+// transient.
+func (c *Client) reconnect(ctx context.Context, broken *stream) (*stream, error) {
+	c.reconnectMu.Lock()
+	defer c.reconnectMu.Unlock()
+	c.mu.Lock()
+	st, initialized := c.stream, c.initialized
+	c.mu.Unlock()
+	if st != broken {
+		// Replaced while waiting for the lock
+		return st, nil
+	}
+
+	st, err := c.open()
+	if err == nil {
+		go c.receive(st)
+		if initialized {
+			if _, err = c.handshake(ctx, st); err != nil {
+				st.cancel()
+			}
+		}
+	}
+	if err != nil {
+		if !Transient(err) && ctx.Err() == nil {
+			c.fail(err)
+		}
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.stream = st
+	c.mu.Unlock()
+	broken.cancel()
+	return st, nil
+}
+
+func (c *Client) send(st *stream, msg *pb.GenericJSONRPCMessage) error {
+	st.sendMu.Lock()
+	defer st.sendMu.Unlock()
+	select {
+	case <-c.done:
+		return c.err
+	case <-st.done:
+		return st.err
+	default:
+	}
+	return st.Send(msg)
+}
+
+// fail ends the client with err, and reports whether it was still running
+func (c *Client) fail(err error) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	c.err = err
+	close(c.done)
+	return true
+}
+
+func (c *Client) receive(st *stream) {
+	for {
+		msg, err := st.Recv()
+		if err != nil {
+			if err == io.EOF {
+				err = errServerClosed
+			}
+			st.err = err
+			close(st.done)
+			// A stream which broke is replaced if the client retries, and
+			// one which failed to replace a broken one is dropped
+			if c.current() == st && (c.retry == nil || !Transient(err)) {
+				c.fail(err)
+			}
+			return
+		}
+		switch {
+		case message.IsResponse(msg):
+			c.mu.Lock()
+			ch := c.calls[msg.GetTypedId().GetNum()]
+			c.mu.Unlock()
+			if ch != nil {
+				// A duplicate or late response of a call already answered
+				// is dropped rather than stalling the stream
+				select {
+				case ch <- msg:
+				default:
+				}
+			}
+		case message.IsRequest(msg):
+			c.send(st, message.NewError(msg.TypedId, methodNotFound, fmt.Sprintf("%s is not supported by this client", msg.Method), nil))
+		case c.onNotification != nil:
+			params, _ := codec.EncodeStruct(msg.Params)
+			c.onNotification(Notification{Method: msg.Method, Params: params})
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/codec"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/message"
+	pb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/jsonrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeServer answers requests with canned JSON results by method. It lists
+// its tools in two pages, its prompts in pages repeating the same cursor,
+// answers "repeat" three times, never answers "slow", and reports the cancelled
+// requests. The first request of a method or tool in breaks breaks its
+// stream.
+type fakeServer struct {
+	pb.UnimplementedJSONRPCServiceServer
+	cancelled chan string
+
+	mu          sync.Mutex
+	breaks      map[string]bool
+	initializes int
+}
+
+// brk reports whether msg is to break its stream, and counts the sessions
+// initialized
+func (s *fakeServer) brk(msg *pb.GenericJSONRPCMessage) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if msg.Method == message.MethodInitialize {
+		s.initializes++
+	}
+	key := msg.Method
+	if name := message.ToolName(msg); name != "" {
+		key = name
+	}
+	if s.breaks[key] {
+		delete(s.breaks, key)
+		return true
+	}
+	return false
+}
+
+var results = map[string]string{
+	"initialize":     `{"protocolVersion":"2025-03-26","capabilities":{"tools":{}},"serverInfo":{"name":"fake","version":"1.0.0"}}`,
+	"tools/call":     `{"content":[{"type":"text","text":"Hello, Ann!"}]}`,
+	"resources/read": `{"contents":[{"uri":"file:///readme","text":"read me"}]}`,
+	"prompts/list":   `{"prompts":[{"name":"greet"}],"nextCursor":"again"}`,
+	"ping":           `{}`,
+}
+
+func (s *fakeServer) Transport(stream pb.JSONRPCService_TransportServer) error {
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if msg.Method == message.MethodCancelled {
+			s.cancelled <- msg.Params.Fields["reason"].GetStringValue()
+			continue
+		}
+		if !message.IsRequest(msg) {
+			continue
+		}
+		if s.brk(msg) {
+			return status.Error(codes.Unavailable, "replica going away")
+		}
+
+		result, ok := results[msg.Method]
+		switch {
+		case msg.Method == "tools/list" && msg.Params.GetFields()["cursor"].GetStringValue() == "":
+			result, ok = `{"tools":[{"name":"hello","inputSchema":{"type":"object"}}],"nextCursor":"2"}`, true
+		case msg.Method == "tools/list":
+			result, ok = `{"tools":[{"name":"get_weather","inputSchema":{"type":"object"}}]}`, true
+		case msg.Method == "slow":
+			// Notify instead of answering
+			note, _ := codec.Decode([]byte(`{"jsonrpc":"2.0","method":"notifications/message","params":{"data":"working"}}`))
+			if err := stream.Send(note); err != nil {
+				return err
+			}
+			continue
+		}
+		resp := message.NewError(msg.TypedId, -32601, "method not found", map[string]string{"method": msg.Method})
+		if ok {
+			r, err := codec.DecodeStruct([]byte(result))
+			if err != nil {
+				return err
+			}
+			resp = &pb.GenericJSONRPCMessage{Jsonrpc: codec.Version, TypedId: msg.TypedId, Result: r}
+		}
+		sends := 1
+		if msg.Method == "repeat" {
+			sends = 3
+		}
+		for range sends {
+			if err := stream.Send(resp); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// serve serves srv over an in-memory listener, and returns a connection to it
+func serve(t *testing.T, srv *fakeServer) *grpc.ClientConn {
+	lis := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer()
+	pb.RegisterJSONRPCServiceServer(gs, srv)
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestClient(t *testing.T) {
+	srv := &fakeServer{cancelled: make(chan string, 1)}
+	conn := serve(t, srv)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	notes := make(chan Notification, 1)
+	c, err := New(ctx, conn, WithNotificationHandler(func(n Notification) { notes <- n }))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := c.Initialize(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ServerInfo.Name != "fake" {
+		t.Errorf("expected server name 'fake', got '%s'", info.ServerInfo.Name)
+	}
+
+	tools, err := c.ListTools(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tools) != 2 || tools[0].Name != "hello" || tools[1].Name != "get_weather" {
+		t.Errorf("expected the tools of both pages, got %+v", tools)
+	}
+
+	if _, err := c.ListPrompts(ctx); err == nil {
+		t.Error("expected a repeated cursor to fail the listing")
+	}
+
+	result, err := c.CallTool(ctx, "hello", map[string]any{"name": "Ann"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IsError || result.Text() != "Hello, Ann!" {
+		t.Errorf("expected 'Hello, Ann!', got %+v", result)
+	}
+
+	contents, err := c.ReadResource(ctx, "file:///readme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contents.Contents) != 1 || contents.Contents[0].Text != "read me" {
+		t.Errorf("expected 'read me', got %+v", contents.Contents)
+	}
+
+	if err := c.Call(ctx, "repeat", nil, nil); err == nil {
+		t.Error("expected a method not found error")
+	}
+	if err := c.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var rpcErr *Error
+	if _, err := c.GetPrompt(ctx, "greet", nil); !errors.As(err, &rpcErr) || rpcErr.Code != -32601 || string(rpcErr.Data) != `{"method":"prompts/get"}` {
+		t.Errorf("expected a method not found error, got %v", err)
+	}
+
+	slowCtx, slowCancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer slowCancel()
+	if err := c.Call(slowCtx, "slow", nil, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a deadline exceeded error, got %v", err)
+	}
+	if n := <-notes; n.Method != "notifications/message" || string(n.Params) != `{"data":"working"}` {
+		t.Errorf("expected the log notification, got %+v", n)
+	}
+	if reason := <-srv.cancelled; reason != context.DeadlineExceeded.Error() {
+		t.Errorf("expected the request to be cancelled, got reason %q", reason)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	<-c.Done()
+	if err := c.Ping(ctx); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed after close, got %v", err)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	srv := &fakeServer{cancelled: make(chan string, 1), breaks: map[string]bool{"ping": true, "get_weather": true, "hello": true}}
+	conn := serve(t, srv)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c, err := New(ctx, conn, WithRetry(&RetryPolicy{InitialBackoff: time.Millisecond, Tools: []string{"hello"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Initialize(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Ping(ctx); err != nil {
+		t.Errorf("expected the ping to be retried, got %v", err)
+	}
+	if _, err := c.CallTool(ctx, "get_weather", nil); status.Code(err) != codes.Unavailable {
+		t.Errorf("expected the call of a tool not allowed to be retried to fail, got %v", err)
+	}
+	if _, err := c.CallTool(ctx, "hello", nil); err != nil {
+		t.Errorf("expected the call of an allowed tool to be retried, got %v", err)
+	}
+	if err := c.Err(); err != nil {
+		t.Errorf("expected the client to replace its broken streams, got %v", err)
+	}
+	// The first stream, and the three replacing a broken one
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.initializes != 4 {
+		t.Errorf("expected every stream to be initialized, got %d initialize requests", srv.initializes)
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	p := &RetryPolicy{MaxAttempts: 100, Budget: 0.5}
+	for i := range 10 {
+		if !p.Retry(1) {
+			t.Fatalf("expected retry %d of the burst to be allowed", i+1)
+		}
+	}
+	if p.Retry(1) {
+		t.Error("expected the budget to be spent")
+	}
+	p.Sent()
+	p.Sent()
+	if !p.Retry(1) || p.Retry(1) {
+		t.Error("expected two requests to earn one retry")
+	}
+	if p.Retry(100) {
+		t.Error("expected no retry after the last attempt")
+	}
+}
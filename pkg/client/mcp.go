@@ -0,0 +1,228 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/message"
+)
+
+// Error is a JSON-RPC error returned by the server
+type Error struct {
+	Code    int
+	Message string
+	// Data is the JSON of the error's data, if any
+	Data json.RawMessage
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+	if len(e.Data) > 0 {
+		msg += ": " + string(e.Data)
+	}
+	return msg
+}
+
+// Notification is a notification from the server
+type Notification struct {
+	Method string
+	// Params is the JSON of the notification's params, if any
+	Params json.RawMessage
+}
+
+// Implementation names a client or server in the initialize handshake
+type Implementation struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type InitializeResult struct {
+	ProtocolVersion string                     `json:"protocolVersion"`
+	Capabilities    map[string]json.RawMessage `json:"capabilities"`
+	ServerInfo      Implementation             `json:"serverInfo"`
+	Instructions    string                     `json:"instructions,omitempty"`
+}
+
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"inputSchema"`
+	Annotations json.RawMessage `json:"annotations,omitempty"`
+}
+
+// Content is an item of a tool result or a prompt message: text, an image
+// or audio in Data, an embedded resource, or a link to a resource in URI
+type Content struct {
+	Type     string            `json:"type"`
+	Text     string            `json:"text,omitempty"`
+	Data     string            `json:"data,omitempty"`
+	MimeType string            `json:"mimeType,omitempty"`
+	Resource *ResourceContents `json:"resource,omitempty"`
+	URI      string            `json:"uri,omitempty"`
+}
+
+type CallToolResult struct {
+	Content           []Content       `json:"content"`
+	StructuredContent json.RawMessage `json:"structuredContent,omitempty"`
+	// IsError is set when the tool failed. Its error is described in the
+	// content.
+	IsError bool `json:"isError,omitempty"`
+}
+
+// Text returns the text content of the result, one item per line
+func (r *CallToolResult) Text() string {
+	var texts []string
+	for _, c := range r.Content {
+		if c.Type == "text" {
+			texts = append(texts, c.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ResourceContents is the text, or the base64 encoded blob, of a resource
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"`
+}
+
+type ReadResourceResult struct {
+	Contents []ResourceContents `json:"contents"`
+}
+
+type Prompt struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+type PromptMessage struct {
+	Role    string  `json:"role"`
+	Content Content `json:"content"`
+}
+
+type GetPromptResult struct {
+	Description string          `json:"description,omitempty"`
+	Messages    []PromptMessage `json:"messages"`
+}
+
+// ListTools returns the tools of the server, fetching every page
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	return List[Tool](ctx, c, "tools/list", "tools")
+}
+
+// ListResources returns the resources of the server, fetching every page
+func (c *Client) ListResources(ctx context.Context) ([]Resource, error) {
+	return List[Resource](ctx, c, "resources/list", "resources")
+}
+
+// ListPrompts returns the prompts of the server, fetching every page
+func (c *Client) ListPrompts(ctx context.Context) ([]Prompt, error) {
+	return List[Prompt](ctx, c, "prompts/list", "prompts")
+}
+
+// List fetches every page of a list method and returns the items of field.
+// T may be json.RawMessage to keep the items as the server sent them. A
+// server which repeats a cursor fails the listing rather than looping.
+func List[T any](ctx context.Context, c *Client, method, field string) ([]T, error) {
+	var items []T
+	seen := map[string]bool{}
+	cursor := ""
+	for {
+		var params map[string]any
+		if cursor != "" {
+			params = map[string]any{"cursor": cursor}
+		}
+		var page map[string]json.RawMessage
+		if err := c.Call(ctx, method, params, &page); err != nil {
+			return nil, err
+		}
+		var pageItems []T
+		if page[field] != nil {
+			if err := json.Unmarshal(page[field], &pageItems); err != nil {
+				return nil, fmt.Errorf("%s: %w", method, err)
+			}
+		}
+		items = append(items, pageItems...)
+
+		cursor = ""
+		if next := page["nextCursor"]; next != nil {
+			if err := json.Unmarshal(next, &cursor); err != nil {
+				return nil, fmt.Errorf("%s: nextCursor: %w", method, err)
+			}
+		}
+		if cursor == "" {
+			return items, nil
+		}
+		if seen[cursor] {
+			return nil, fmt.Errorf("%s: the server repeated the cursor %q", method, cursor)
+		}
+		seen[cursor] = true
+	}
+}
+
+// CallTool calls a tool with arguments, which are encoded as a JSON object
+// and may be nil. A tool which fails returns a result with IsError set
+// rather than an error.
+func (c *Client) CallTool(ctx context.Context, name string, arguments any) (*CallToolResult, error) {
+	params := map[string]any{"name": name}
+	if arguments != nil {
+		params["arguments"] = arguments
+	}
+	var result CallToolResult
+	if err := c.Call(ctx, message.MethodToolsCall, params, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) ReadResource(ctx context.Context, uri string) (*ReadResourceResult, error) {
+	var result ReadResourceResult
+	if err := c.Call(ctx, message.MethodResourcesRead, map[string]any{"uri": uri}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Subscribe asks the server to send notifications/resources/updated when the
+// resource changes, which are passed to the notification handler
+func (c *Client) Subscribe(ctx context.Context, uri string) error {
+	return c.Call(ctx, "resources/subscribe", map[string]any{"uri": uri}, nil)
+}
+
+func (c *Client) Unsubscribe(ctx context.Context, uri string) error {
+	return c.Call(ctx, "resources/unsubscribe", map[string]any{"uri": uri}, nil)
+}
+
+func (c *Client) GetPrompt(ctx context.Context, name string, arguments map[string]string) (*GetPromptResult, error) {
+	params := map[string]any{"name": name}
+	if arguments != nil {
+		params["arguments"] = arguments
+	}
+	var result GetPromptResult
+	if err := c.Call(ctx, message.MethodPromptsGet, params, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) Ping(ctx context.Context) error {
+	return c.Call(ctx, "ping", nil, nil)
+}
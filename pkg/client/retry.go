@@ -0,0 +1,129 @@
+package client
+
+import (
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/message"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// idempotentMethods are the methods whose requests have no side effects, so
+// that sending them again after the stream broke is safe
+var idempotentMethods = map[string]bool{
+	"tools/list":     true,
+	"resources/list": true,
+	"resources/read": true,
+	"prompts/list":   true,
+	"prompts/get":    true,
+	"ping":           true,
+}
+
+// retryBurst is the number of retries the budget holds when full
+const retryBurst = 10
+
+// RetryPolicy retries the requests of idempotent methods which failed as
+// their stream broke, on a new stream, with exponential backoff. Retries are
+// budgeted: every request sent adds Budget to the budget, which holds up to
+// 10 retries, and every retry takes one, so that a failing server is not
+// flooded with them. A policy may be shared by clients, which then share its
+// budget. Zero fields take their defaults.
+type RetryPolicy struct {
+	// MaxAttempts of a request, including the first; 3 by default
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry, doubled for
+	// every further one up to MaxBackoff, with jitter; 100ms and 2s by
+	// default
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Budget is the share of retries per request sent; 0.1 by default
+	Budget float64
+	// Tools are the tools which are safe to call again, as they have no
+	// side effects. Calls of other tools are never retried.
+	Tools []string
+
+	mu     sync.Mutex
+	filled bool
+	tokens float64
+}
+
+// Idempotent reports whether a request of method, calling tool if it is a
+// tools/call request, may be sent again after its stream broke
+func (p *RetryPolicy) Idempotent(method, tool string) bool {
+	if method == message.MethodToolsCall {
+		return slices.Contains(p.Tools, tool)
+	}
+	return idempotentMethods[method]
+}
+
+// Sent adds a request sent for the first time to the budget
+func (p *RetryPolicy) Sent() {
+	budget := p.Budget
+	if budget == 0 {
+		budget = 0.1
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fill()
+	p.tokens = min(p.tokens+budget, retryBurst)
+}
+
+// Retry reports whether a request which failed on the given attempt,
+// counted from 1, may be sent again, and takes the retry from the budget if
+// so
+func (p *RetryPolicy) Retry(attempt int) bool {
+	maxAttempts := p.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = 3
+	}
+	if attempt >= maxAttempts {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fill()
+	if p.tokens < 1 {
+		return false
+	}
+	p.tokens--
+	return true
+}
+
+func (p *RetryPolicy) fill() {
+	if !p.filled {
+		p.filled = true
+		p.tokens = retryBurst
+	}
+}
+
+// Backoff returns the delay before the given retry, counted from 1: a random
+// duration between half and all of InitialBackoff*2^(retry-1), capped at
+// MaxBackoff
+func (p *RetryPolicy) Backoff(retry int) time.Duration {
+	d, limit := p.InitialBackoff, p.MaxBackoff
+	if d == 0 {
+		d = 100 * time.Millisecond
+	}
+	if limit == 0 {
+		limit = 2 * time.Second
+	}
+	for i := 1; i < retry && d < limit; i++ {
+		d *= 2
+	}
+	d = min(d, limit)
+	return d/2 + rand.N(d/2+1)
+}
+
+// errServerClosed ends a stream which the server closed without an error
+var errServerClosed = errors.New("the server closed the session")
+
+// Transient reports whether err, which broke a stream, is a failure of the
+// connection or of the server going away, after which a new stream may
+// succeed
+func Transient(err error) bool {
+	return errors.Is(err, errServerClosed) || status.Code(err) == codes.Unavailable
+}
@@ -0,0 +1,371 @@
+// Package codec converts between JSON-RPC 2.0 messages, as exchanged with a
+// stdio MCP client or server, and the GenericJSONRPCMessage frames carried
+// over gRPC. Decoding validates the message, and encoding produces the same
+// bytes for the same message regardless of how it was built.
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	pb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/jsonrpc"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Version is the only JSON-RPC version accepted and produced
+const Version = "2.0"
+
+// JSON-RPC error codes of messages which cannot be decoded
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+)
+
+// maxExactInt is the largest integer a protobuf Struct number holds exactly
+const maxExactInt = 1 << 53
+
+// Error is a message which is not valid JSON-RPC 2.0
+type Error struct {
+	Code    int32
+	Message string
+	// ID is the ID of the invalid message, if it could be read
+	ID *pb.ID
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Response answers the invalid message the way a JSON-RPC server would,
+// with an ID of null if the message's own could not be read
+func (e *Error) Response() *pb.GenericJSONRPCMessage {
+	return &pb.GenericJSONRPCMessage{
+		Jsonrpc: Version,
+		TypedId: e.ID,
+		Error:   &pb.JSONRPCError{Code: e.Code, Message: e.Message},
+	}
+}
+
+type wireMessage struct {
+	JSONRPC *string         `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Method  *string         `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	Result  json.RawMessage `json:"result"`
+	Error   *wireError      `json:"error"`
+}
+
+type wireError struct {
+	Code    *int32          `json:"code"`
+	Message *string         `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// Decode parses a single JSON-RPC message. Invalid messages are reported with
+// an *Error. Numbers in params and results must be representable exactly as
+// a float64, which is how a protobuf Struct stores them.
+func Decode(b []byte) (*pb.GenericJSONRPCMessage, error) {
+	if !json.Valid(b) {
+		return nil, &Error{Code: CodeParseError, Message: "Parse error"}
+	}
+	switch t := bytes.TrimLeft(b, " \t\r\n"); {
+	case len(t) > 0 && t[0] == '[':
+		return nil, invalid(nil, "batches are not supported")
+	case len(t) == 0 || t[0] != '{':
+		return nil, invalid(nil, "message must be an object")
+	}
+
+	var w wireMessage
+	if err := json.Unmarshal(b, &w); err != nil {
+		return nil, invalid(nil, err.Error())
+	}
+
+	id, err := decodeID(w.ID)
+	if err != nil {
+		return nil, invalid(nil, err.Error())
+	}
+	if w.JSONRPC == nil || *w.JSONRPC != Version {
+		return nil, invalid(id, `"jsonrpc" must be "2.0"`)
+	}
+
+	m := &pb.GenericJSONRPCMessage{Jsonrpc: Version, TypedId: id}
+	isResponse := w.Result != nil || w.Error != nil
+	switch {
+	case w.Method != nil && isResponse:
+		return nil, invalid(id, "message cannot have both a method and a result or error")
+	case w.Method != nil:
+		if *w.Method == "" {
+			return nil, invalid(id, `"method" must not be empty`)
+		}
+		if w.ID != nil && id == nil {
+			return nil, invalid(nil, "request id must not be null")
+		}
+		m.Method = *w.Method
+		if m.Params, err = decodeObject("params", w.Params); err != nil {
+			return nil, invalid(id, err.Error())
+		}
+	case w.Result != nil && w.Error != nil:
+		return nil, invalid(id, "response cannot have both a result and an error")
+	case w.Result != nil:
+		if id == nil {
+			return nil, invalid(nil, "response must have an id")
+		}
+		if m.Result, err = decodeObject("result", w.Result); err != nil {
+			return nil, invalid(id, err.Error())
+		}
+		if m.Result == nil {
+			return nil, invalid(id, `"result" must be an object`)
+		}
+	case w.Error != nil:
+		if w.ID == nil {
+			return nil, invalid(nil, "error response must have an id, or null")
+		}
+		if w.Error.Code == nil || w.Error.Message == nil {
+			return nil, invalid(id, `"error" must have a code and a message`)
+		}
+		m.Error = &pb.JSONRPCError{Code: *w.Error.Code, Message: *w.Error.Message}
+		if len(w.Error.Data) > 0 {
+			var data bytes.Buffer
+			if err := json.Compact(&data, w.Error.Data); err != nil {
+				return nil, invalid(id, err.Error())
+			}
+			if err := setData(m.Error, data.Bytes()); err != nil {
+				return nil, invalid(id, `"data": `+err.Error())
+			}
+		}
+	default:
+		return nil, invalid(id, "message must have a method, a result or an error")
+	}
+	return m, nil
+}
+
+func invalid(id *pb.ID, msg string) *Error {
+	return &Error{Code: CodeInvalidRequest, Message: "Invalid request: " + msg, ID: id}
+}
+
+func decodeID(raw json.RawMessage) (*pb.ID, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return &pb.ID{Kind: &pb.ID_Str{Str: s}}, nil
+	}
+	n, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf(`"id" %s must be a string or a 64-bit integer`, raw)
+	}
+	return &pb.ID{Kind: &pb.ID_Num{Num: n}}, nil
+}
+
+func decodeObject(field string, raw json.RawMessage) (*structpb.Struct, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	if bytes.TrimLeft(raw, " \t\r\n")[0] != '{' {
+		return nil, fmt.Errorf("%q must be an object", field)
+	}
+	return DecodeStruct(raw)
+}
+
+// DecodeStruct parses a JSON object into a Struct. Integers beyond ±2^53 are
+// rejected rather than silently rounded.
+func DecodeStruct(raw []byte) (*structpb.Struct, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v map[string]any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if _, err := exactNumbers(v); err != nil {
+		return nil, err
+	}
+	return structpb.NewStruct(v)
+}
+
+// decodeValue parses any JSON value into a Value, rejecting integers beyond
+// ±2^53 like DecodeStruct
+func decodeValue(raw []byte) (*structpb.Value, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	v, err := exactNumbers(v)
+	if err != nil {
+		return nil, err
+	}
+	return structpb.NewValue(v)
+}
+
+// exactNumbers replaces the json.Numbers in a decoded value by float64s, and
+// fails for integers which a float64 cannot hold
+func exactNumbers(v any) (any, error) {
+	switch v := v.(type) {
+	case json.Number:
+		if !strings.ContainsAny(string(v), ".eE") {
+			n, ok := new(big.Int).SetString(string(v), 10)
+			if !ok || n.CmpAbs(big.NewInt(maxExactInt)) > 0 {
+				return nil, fmt.Errorf("number %s cannot be represented exactly", v)
+			}
+		}
+		return v.Float64()
+	case map[string]any:
+		for k, e := range v {
+			e, err := exactNumbers(e)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = e
+		}
+	case []any:
+		for i, e := range v {
+			e, err := exactNumbers(e)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = e
+		}
+	}
+	return v, nil
+}
+
+// Encode produces the JSON-RPC representation of a message, with its members
+// in the order jsonrpc, id, method, params, result, error and without
+// insignificant whitespace. Responses and errors without an ID get an id of
+// null, and a response without a result an empty one.
+func Encode(m *pb.GenericJSONRPCMessage) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(`{"jsonrpc":"2.0"`)
+
+	switch v := m.GetTypedId().GetKind().(type) {
+	case *pb.ID_Num:
+		buf.WriteString(`,"id":` + strconv.FormatInt(v.Num, 10))
+	case *pb.ID_Str:
+		buf.WriteString(`,"id":`)
+		writeJSON(&buf, v.Str)
+	default:
+		if m.GetMethod() == "" {
+			buf.WriteString(`,"id":null`)
+		}
+	}
+
+	if m.GetMethod() != "" {
+		buf.WriteString(`,"method":`)
+		writeJSON(&buf, m.GetMethod())
+		if m.GetParams() != nil {
+			buf.WriteString(`,"params":`)
+			if err := writeStruct(&buf, m.GetParams()); err != nil {
+				return nil, err
+			}
+		}
+	} else if e := m.GetError(); e != nil {
+		buf.WriteString(`,"error":{"code":` + strconv.FormatInt(int64(e.Code), 10) + `,"message":`)
+		writeJSON(&buf, e.Message)
+		if data := ErrorData(e); data != nil {
+			buf.WriteString(`,"data":`)
+			buf.Write(data)
+		}
+		buf.WriteString(`}`)
+	} else {
+		buf.WriteString(`,"result":`)
+		if m.GetResult() == nil {
+			buf.WriteString(`{}`)
+		} else if err := writeStruct(&buf, m.GetResult()); err != nil {
+			return nil, err
+		}
+	}
+
+	buf.WriteString(`}`)
+	return buf.Bytes(), nil
+}
+
+// EncodeStruct produces the JSON representation of a Struct, with sorted
+// keys and without insignificant whitespace
+func EncodeStruct(s *structpb.Struct) (json.RawMessage, error) {
+	var buf bytes.Buffer
+	if err := writeStruct(&buf, s); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SetData sets the data of an error to any JSON value: in data_value, and in
+// data as a plain string for peers which predate it, which is the data itself
+// if it is a string, or else its JSON encoding. Nil data, and data which
+// cannot be encoded, is left out.
+func SetData(e *pb.JSONRPCError, data any) {
+	var buf bytes.Buffer
+	switch v := data.(type) {
+	case nil:
+		return
+	case json.RawMessage:
+		if json.Compact(&buf, v) != nil {
+			return
+		}
+	default:
+		if encode(&buf, v) != nil {
+			return
+		}
+	}
+	_ = setData(e, buf.Bytes())
+}
+
+// setData sets the data of an error to the compact JSON in raw
+func setData(e *pb.JSONRPCError, raw []byte) error {
+	value, err := decodeValue(raw)
+	if err != nil {
+		return err
+	}
+	e.DataValue = value
+	if s, ok := value.GetKind().(*structpb.Value_StringValue); ok {
+		e.Data = s.StringValue
+	} else {
+		e.Data = string(raw)
+	}
+	return nil
+}
+
+// ErrorData returns the JSON of an error's data, or nil if it has none. An
+// error without data_value, as sent by peers which predate it, carries its
+// data field as a plain string.
+func ErrorData(e *pb.JSONRPCError) json.RawMessage {
+	var buf bytes.Buffer
+	switch {
+	case e.GetDataValue() != nil:
+		if encode(&buf, e.GetDataValue().AsInterface()) != nil {
+			return nil
+		}
+	case e.GetData() != "":
+		writeJSON(&buf, e.GetData())
+	default:
+		return nil
+	}
+	return buf.Bytes()
+}
+
+func writeStruct(buf *bytes.Buffer, s *structpb.Struct) error {
+	return encode(buf, s.AsMap())
+}
+
+func writeJSON(buf *bytes.Buffer, v string) {
+	// Strings cannot fail to encode
+	_ = encode(buf, v)
+}
+
+// encode writes v without escaping HTML characters, unlike json.Marshal, as
+// stdio MCP peers do not either
+func encode(buf *bytes.Buffer, v any) error {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	buf.Truncate(buf.Len() - 1) // the newline added by Encode
+	return nil
+}
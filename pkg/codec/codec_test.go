@@ -0,0 +1,108 @@
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	pb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/jsonrpc"
+)
+
+func TestRoundTrip(t *testing.T) {
+	// Messages as a stdio peer writes them, which must come out unchanged
+	lines := []string{
+		`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"arguments":{"n":9007199254740992,"q":"a<b && c>d","x":0.1},"name":"echo"}}`,
+		`{"jsonrpc":"2.0","id":"abc","result":{"content":[{"text":"hi","type":"text"}],"isError":false}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","method":"notifications/progress","params":{"progress":50,"progressToken":"t","total":100}}`,
+		`{"jsonrpc":"2.0","id":2,"error":{"code":-32005,"message":"rate limited","data":{"retryAfterMs":100}}}`,
+		`{"jsonrpc":"2.0","id":3,"error":{"code":-1,"message":"x","data":"plain"}}`,
+		`{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"Parse error"}}`,
+		`{"jsonrpc":"2.0","id":4,"result":{}}`,
+	}
+	for _, line := range lines {
+		m, err := Decode([]byte(line))
+		if err != nil {
+			t.Errorf("Decode(%s): %v", line, err)
+			continue
+		}
+		out, err := Encode(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != line {
+			t.Errorf("round trip changed the message:\n got %s\nwant %s", out, line)
+		}
+	}
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	tests := []struct {
+		line string
+		code int32
+		id   *pb.ID
+	}{
+		{`{"jsonrpc":"2.0","id":1,`, CodeParseError, nil},
+		{`[{"jsonrpc":"2.0","id":1,"method":"ping"}]`, CodeInvalidRequest, nil},
+		{`{"jsonrpc":"1.0","id":1,"method":"ping"}`, CodeInvalidRequest, &pb.ID{Kind: &pb.ID_Num{Num: 1}}},
+		{`{"id":"a","method":"ping"}`, CodeInvalidRequest, &pb.ID{Kind: &pb.ID_Str{Str: "a"}}},
+		{`{"jsonrpc":"2.0","id":null,"method":"ping"}`, CodeInvalidRequest, nil},
+		{`{"jsonrpc":"2.0","id":1.5,"method":"ping"}`, CodeInvalidRequest, nil},
+		{`{"jsonrpc":"2.0","id":1,"method":"ping","params":[1]}`, CodeInvalidRequest, &pb.ID{Kind: &pb.ID_Num{Num: 1}}},
+		{`{"jsonrpc":"2.0","id":1,"result":{},"error":{"code":1,"message":"x"}}`, CodeInvalidRequest, &pb.ID{Kind: &pb.ID_Num{Num: 1}}},
+		{`{"jsonrpc":"2.0","result":{}}`, CodeInvalidRequest, nil},
+		{`{"jsonrpc":"2.0","id":1,"method":"x","params":{"n":9007199254740993}}`, CodeInvalidRequest, &pb.ID{Kind: &pb.ID_Num{Num: 1}}},
+		{`{"jsonrpc":"2.0","id":1,"error":{"code":1,"message":"x","data":9007199254740993}}`, CodeInvalidRequest, &pb.ID{Kind: &pb.ID_Num{Num: 1}}},
+	}
+	for _, tt := range tests {
+		_, err := Decode([]byte(tt.line))
+		var e *Error
+		if !errors.As(err, &e) {
+			t.Errorf("Decode(%s): expected an *Error, got %v", tt.line, err)
+			continue
+		}
+		if e.Code != tt.code || e.ID.String() != tt.id.String() {
+			t.Errorf("Decode(%s): got code %d and id %v, want %d and %v", tt.line, e.Code, e.ID, tt.code, tt.id)
+		}
+	}
+
+	out, _ := Encode((&Error{Code: CodeParseError, Message: "Parse error"}).Response())
+	if string(out) != `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"Parse error"}}` {
+		t.Errorf("unexpected error response: %s", out)
+	}
+}
+
+func TestSetData(t *testing.T) {
+	for _, tt := range []struct {
+		data      any
+		legacy    string
+		dataValue string
+	}{
+		{nil, "", ""},
+		{"oops", "oops", `"oops"`},
+		{"123", "123", `"123"`},
+		{123, "123", `123`},
+		{map[string]any{"retryAfterMs": 100}, `{"retryAfterMs":100}`, `{"retryAfterMs":100}`},
+		{json.RawMessage(`{ "a" : [1, 2] }`), `{"a":[1,2]}`, `{"a":[1,2]}`},
+	} {
+		e := &pb.JSONRPCError{}
+		SetData(e, tt.data)
+		if e.Data != tt.legacy || string(ErrorData(e)) != tt.dataValue {
+			t.Errorf("SetData(%v): got data %q and %s, want %q and %s", tt.data, e.Data, ErrorData(e), tt.legacy, tt.dataValue)
+		}
+	}
+
+	// Peers which predate data_value only send data, as a plain string
+	for data, want := range map[string]string{
+		"not json": `"not json"`,
+		"123":      `"123"`,
+		"json:{}":  `"json:{}"`,
+	} {
+		if got := string(ErrorData(&pb.JSONRPCError{Data: data})); got != want {
+			t.Errorf("ErrorData(%q) = %s, want %s", data, got, want)
+		}
+	}
+	if got := ErrorData(&pb.JSONRPCError{}); got != nil {
+		t.Errorf("expected no data, got %s", got)
+	}
+}
@@ -0,0 +1,207 @@
+// Package hooks lets applications observe the lifecycle of the sessions and
+// messages handled by the gRPC server transports, e.g. for billing, alerting
+// or analytics, without wrapping the transports themselves.
+package hooks
+
+import (
+	"time"
+
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/message"
+	pb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/jsonrpc"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/session"
+)
+
+// Directions of a conversion error
+const (
+	DirectionIn  = "in"
+	DirectionOut = "out"
+)
+
+// Hooks holds the callbacks of a server transport. Any of them may be nil.
+// They are called synchronously from the session's goroutines, so they must
+// be safe for concurrent use and should not block.
+type Hooks struct {
+	// OnStreamOpen is called when an authenticated Transport stream starts
+	OnStreamOpen func(StreamOpenEvent)
+	// OnStreamClose is called when a Transport stream ends
+	OnStreamClose func(StreamCloseEvent)
+	// OnInitialized is called once the initialize request of a session
+	// was answered successfully
+	OnInitialized func(InitializedEvent)
+	// OnRequest is called for every request received from the client,
+	// before it is authorized, rate limited or handled
+	OnRequest func(RequestEvent)
+	// OnResponse is called for every response sent to the client,
+	// including the errors sent by the transport itself
+	OnResponse func(ResponseEvent)
+	// OnNotification is called for every notification sent to the client
+	OnNotification func(NotificationEvent)
+	// OnConversionError is called when a message cannot be converted
+	// between its protobuf and JSON-RPC representations
+	OnConversionError func(ConversionErrorEvent)
+}
+
+// StreamOpenEvent describes a newly opened session
+type StreamOpenEvent struct {
+	Session *session.Info
+}
+
+// StreamCloseEvent describes a session which ended
+type StreamCloseEvent struct {
+	Session *session.Info
+	// Err is the error the stream ended with, or nil if the client closed it
+	Err              error
+	Duration         time.Duration
+	MessagesReceived uint64
+	MessagesSent     uint64
+}
+
+// ClientInfo identifies the MCP client of a session
+type ClientInfo struct {
+	Name    string
+	Version string
+}
+
+// InitializedEvent describes a session whose initialize handshake completed
+type InitializedEvent struct {
+	Session    *session.Info
+	ClientInfo ClientInfo
+	// ProtocolVersion is the version negotiated by the server
+	ProtocolVersion string
+	Request         *pb.GenericJSONRPCMessage
+	Response        *pb.GenericJSONRPCMessage
+}
+
+// RequestEvent describes a request received from the client
+type RequestEvent struct {
+	Session *session.Info
+	Method  string
+	// Tool is the tool name of a tools/call request
+	Tool    string
+	Request *pb.GenericJSONRPCMessage
+}
+
+// ResponseEvent describes a response sent to the client
+type ResponseEvent struct {
+	Session  *session.Info
+	Method   string
+	Tool     string
+	Request  *pb.GenericJSONRPCMessage
+	Response *pb.GenericJSONRPCMessage
+	// Duration is the time from receiving the request until its response
+	// was sent
+	Duration time.Duration
+}
+
+// Error returns the JSON-RPC error of the response, if any
+func (e ResponseEvent) Error() *pb.JSONRPCError {
+	return e.Response.GetError()
+}
+
+// NotificationEvent describes a notification sent to the client
+type NotificationEvent struct {
+	Session      *session.Info
+	Method       string
+	Notification *pb.GenericJSONRPCMessage
+}
+
+// ConversionErrorEvent describes a message which could not be converted
+type ConversionErrorEvent struct {
+	Session   *session.Info
+	Direction string
+	// Message is the *pb.GenericJSONRPCMessage received from the client,
+	// or the MCP library's message which was to be sent
+	Message any
+	Err     error
+}
+
+// StreamOpened calls OnStreamOpen. All methods are safe to call on nil Hooks.
+func (h *Hooks) StreamOpened(info *session.Info) {
+	if h == nil || h.OnStreamOpen == nil {
+		return
+	}
+	h.OnStreamOpen(StreamOpenEvent{Session: info})
+}
+
+// StreamClosed calls OnStreamClose
+func (h *Hooks) StreamClosed(info *session.Info, err error, received, sent uint64) {
+	if h == nil || h.OnStreamClose == nil {
+		return
+	}
+	h.OnStreamClose(StreamCloseEvent{
+		Session:          info,
+		Err:              err,
+		Duration:         time.Since(info.StartedAt),
+		MessagesReceived: received,
+		MessagesSent:     sent,
+	})
+}
+
+// Received calls OnRequest if the message is a request
+func (h *Hooks) Received(info *session.Info, m *pb.GenericJSONRPCMessage) {
+	if h == nil || h.OnRequest == nil || !message.IsRequest(m) {
+		return
+	}
+	h.OnRequest(RequestEvent{
+		Session: info,
+		Method:  m.Method,
+		Tool:    message.ToolName(m),
+		Request: m,
+	})
+}
+
+// Responded calls OnResponse, and OnInitialized if the response completed
+// the initialize handshake
+func (h *Hooks) Responded(info *session.Info, req, resp *pb.GenericJSONRPCMessage, start time.Time) {
+	if h == nil {
+		return
+	}
+	if h.OnResponse != nil {
+		h.OnResponse(ResponseEvent{
+			Session:  info,
+			Method:   req.GetMethod(),
+			Tool:     message.ToolName(req),
+			Request:  req,
+			Response: resp,
+			Duration: time.Since(start),
+		})
+	}
+	if h.OnInitialized != nil && req.GetMethod() == message.MethodInitialize && resp.GetError() == nil {
+		client := req.GetParams().GetFields()["clientInfo"].GetStructValue().GetFields()
+		h.OnInitialized(InitializedEvent{
+			Session: info,
+			ClientInfo: ClientInfo{
+				Name:    client["name"].GetStringValue(),
+				Version: client["version"].GetStringValue(),
+			},
+			ProtocolVersion: resp.GetResult().GetFields()["protocolVersion"].GetStringValue(),
+			Request:         req,
+			Response:        resp,
+		})
+	}
+}
+
+// Notified calls OnNotification if the message is a notification
+func (h *Hooks) Notified(info *session.Info, m *pb.GenericJSONRPCMessage) {
+	if h == nil || h.OnNotification == nil || !message.IsNotification(m) {
+		return
+	}
+	h.OnNotification(NotificationEvent{
+		Session:      info,
+		Method:       m.Method,
+		Notification: m,
+	})
+}
+
+// ConversionFailed calls OnConversionError
+func (h *Hooks) ConversionFailed(info *session.Info, direction string, m any, err error) {
+	if h == nil || h.OnConversionError == nil {
+		return
+	}
+	h.OnConversionError(ConversionErrorEvent{
+		Session:   info,
+		Direction: direction,
+		Message:   m,
+		Err:       err,
+	})
+}
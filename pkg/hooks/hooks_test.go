@@ -0,0 +1,62 @@
+package hooks
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/message"
+	pb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/jsonrpc"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/session"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestHooks_Nil(t *testing.T) {
+	var h *Hooks
+	info := &session.Info{ID: "s1", StartedAt: time.Now()}
+	h.StreamOpened(info)
+	h.Received(info, &pb.GenericJSONRPCMessage{Method: "ping"})
+	h.Responded(info, nil, nil, time.Now())
+	h.Notified(info, nil)
+	h.ConversionFailed(info, DirectionIn, nil, errors.New("boom"))
+	h.StreamClosed(info, nil, 0, 0)
+
+	// Hooks without the relevant callback are skipped too
+	(&Hooks{}).Responded(info, nil, nil, time.Now())
+}
+
+func TestHooks_Initialize(t *testing.T) {
+	id := &pb.ID{Kind: &pb.ID_Num{Num: 1}}
+	params, _ := structpb.NewStruct(map[string]any{
+		"protocolVersion": "2025-03-26",
+		"clientInfo":      map[string]any{"name": "ide", "version": "1.2.3"},
+	})
+	result, _ := structpb.NewStruct(map[string]any{"protocolVersion": "2024-11-05"})
+	req := &pb.GenericJSONRPCMessage{Jsonrpc: "2.0", TypedId: id, Method: message.MethodInitialize, Params: params}
+	resp := &pb.GenericJSONRPCMessage{Jsonrpc: "2.0", TypedId: id, Result: result}
+
+	var requests, responses []string
+	var initialized []InitializedEvent
+	h := &Hooks{
+		OnRequest:     func(e RequestEvent) { requests = append(requests, e.Method) },
+		OnResponse:    func(e ResponseEvent) { responses = append(responses, e.Method) },
+		OnInitialized: func(e InitializedEvent) { initialized = append(initialized, e) },
+	}
+	info := &session.Info{ID: "s1"}
+
+	h.Received(info, req)
+	h.Received(info, &pb.GenericJSONRPCMessage{Method: "notifications/initialized"})
+	h.Responded(info, req, message.NewError(id, -32602, "unsupported version", nil), time.Now())
+	h.Responded(info, req, resp, time.Now())
+
+	if len(requests) != 1 || len(responses) != 2 {
+		t.Errorf("expected 1 request and 2 responses, got %v and %v", requests, responses)
+	}
+	if len(initialized) != 1 {
+		t.Fatalf("expected only the successful response to complete initialize, got %d", len(initialized))
+	}
+	e := initialized[0]
+	if e.ClientInfo != (ClientInfo{Name: "ide", Version: "1.2.3"}) || e.ProtocolVersion != "2024-11-05" || e.Session != info {
+		t.Errorf("unexpected event: %+v", e)
+	}
+}
@@ -0,0 +1,166 @@
+// Package jwtauth authenticates gRPC streams with JWTs verified against a
+// local JWKS, and authorizes MCP methods based on the token's scopes.
+package jwtauth
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorCode is the JSON-RPC error code for requests the token does not permit
+const ErrorCode = -32001
+
+// Config holds the validation rules for tokens
+type Config struct {
+	// Issuer, if set, must match the "iss" claim
+	Issuer string
+	// Audience, if set, must be one of the "aud" claim values
+	Audience string
+	// RequiredScopes must all be granted to open a stream
+	RequiredScopes []string
+	// ScopeMethods maps a scope to the MCP methods it allows. A pattern
+	// ending in "*" matches by prefix, e.g. "tools/*". When empty, every
+	// method is allowed. initialize, ping and notifications are always allowed.
+	ScopeMethods map[string][]string
+	// Leeway tolerates clock skew when checking exp and nbf
+	Leeway time.Duration
+}
+
+// Authenticator validates the bearer tokens of incoming streams
+type Authenticator struct {
+	keys *KeySet
+	cfg  Config
+	now  func() time.Time
+}
+
+// New creates an Authenticator trusting the keys of the KeySet
+func New(keys *KeySet, cfg Config) *Authenticator {
+	return &Authenticator{
+		keys: keys,
+		cfg:  cfg,
+		now:  time.Now,
+	}
+}
+
+// Authenticate validates the bearer token from the "authorization" metadata
+// of a stream. The returned error is a gRPC status error.
+func (a *Authenticator) Authenticate(ctx context.Context) (*Claims, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "missing authorization metadata")
+	}
+	scheme, token, ok := strings.Cut(values[0], " ")
+	if !ok || !strings.EqualFold(scheme, "bearer") {
+		return nil, status.Error(codes.Unauthenticated, "authorization metadata is not a bearer token")
+	}
+
+	claims, err := a.Validate(strings.TrimSpace(token))
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	for _, scope := range a.cfg.RequiredScopes {
+		if !claims.HasScope(scope) {
+			return nil, status.Errorf(codes.PermissionDenied, "missing required scope %q", scope)
+		}
+	}
+	return claims, nil
+}
+
+// Validate verifies a token's signature, issuer, audience and lifetime
+func (a *Authenticator) Validate(token string) (*Claims, error) {
+	claims, err := parse(token, a.keys)
+	if err != nil {
+		return nil, err
+	}
+	if a.cfg.Issuer != "" && claims.Issuer != a.cfg.Issuer {
+		return nil, fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	}
+	if a.cfg.Audience != "" && !slices.Contains(claims.Audience, a.cfg.Audience) {
+		return nil, fmt.Errorf("token is not valid for audience %q", a.cfg.Audience)
+	}
+	if err := a.checkLifetime(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (a *Authenticator) checkLifetime(c *Claims) error {
+	now := a.now()
+	if c.ExpiresAt.IsZero() {
+		return fmt.Errorf("token has no expiry")
+	}
+	if now.After(c.ExpiresAt.Add(a.cfg.Leeway)) {
+		return fmt.Errorf("token expired at %s", c.ExpiresAt.Format(time.RFC3339))
+	}
+	if !c.NotBefore.IsZero() && now.Add(a.cfg.Leeway).Before(c.NotBefore) {
+		return fmt.Errorf("token is not valid before %s", c.NotBefore.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Authorize checks that the token is still valid and grants the MCP method.
+// Streams outlive tokens, so this is checked for every request.
+func (a *Authenticator) Authorize(c *Claims, method string) error {
+	if err := a.checkLifetime(c); err != nil {
+		return err
+	}
+	if len(a.cfg.ScopeMethods) == 0 || method == "" || alwaysAllowed(method) {
+		return nil
+	}
+	for _, scope := range c.Scopes {
+		for _, pattern := range a.cfg.ScopeMethods[scope] {
+			if matchMethod(pattern, method) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("method %q is not permitted by the token's scopes", method)
+}
+
+// RequireScope returns a check that a call carries a valid token granting the
+// scope, e.g. to restrict the admin service with admin.WithAuthorizer.
+func (a *Authenticator) RequireScope(scope string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		claims, err := a.Authenticate(ctx)
+		if err != nil {
+			return err
+		}
+		if !claims.HasScope(scope) {
+			return status.Errorf(codes.PermissionDenied, "missing required scope %q", scope)
+		}
+		return nil
+	}
+}
+
+func alwaysAllowed(method string) bool {
+	return method == "initialize" || method == "ping" || strings.HasPrefix(method, "notifications/")
+}
+
+func matchMethod(pattern, method string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(method, prefix)
+	}
+	return pattern == method
+}
+
+type claimsKey struct{}
+
+// WithClaims stores the verified claims in the context
+func WithClaims(ctx context.Context, c *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, c)
+}
+
+// ClaimsFromContext returns the verified claims of the stream a request was
+// received on. Tool handlers can use it to make their own access decisions.
+func ClaimsFromContext(ctx context.Context) *Claims {
+	c, _ := ctx.Value(claimsKey{}).(*Claims)
+	return c
+}
@@ -0,0 +1,288 @@
+package jwtauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var b64 = base64.RawURLEncoding
+
+func sign(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]any) string {
+	t.Helper()
+	h, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	c, _ := json.Marshal(claims)
+	input := b64.EncodeToString(h) + "." + b64.EncodeToString(c)
+
+	var sig []byte
+	var err error
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(k, []byte(input))
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256([]byte(input))
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256([]byte(input))
+		r, s, e := ecdsa.Sign(rand.Reader, k, digest[:])
+		sig, err = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...), e
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return input + "." + b64.EncodeToString(sig)
+}
+
+type testKeys struct {
+	rsa  *rsa.PrivateKey
+	ec   *ecdsa.PrivateKey
+	ed   ed25519.PrivateKey
+	jwks []byte
+}
+
+func newTestKeys(t *testing.T) *testKeys {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks := fmt.Sprintf(`{"keys":[
+		{"kty":"RSA","kid":"rsa-1","use":"sig","n":%q,"e":%q},
+		{"kty":"EC","kid":"ec-1","crv":"P-256","x":%q,"y":%q},
+		{"kty":"OKP","kid":"ed-1","crv":"Ed25519","x":%q}
+	]}`,
+		b64.EncodeToString(rsaKey.N.Bytes()), b64.EncodeToString([]byte{1, 0, 1}),
+		b64.EncodeToString(ecKey.X.FillBytes(make([]byte, 32))), b64.EncodeToString(ecKey.Y.FillBytes(make([]byte, 32))),
+		b64.EncodeToString(edPub),
+	)
+	return &testKeys{rsa: rsaKey, ec: ecKey, ed: edKey, jwks: []byte(jwks)}
+}
+
+func validClaims() map[string]any {
+	return map[string]any{
+		"iss":   "https://idp.example.com",
+		"sub":   "agent-42",
+		"aud":   []string{"mcp-servers"},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"scope": "mcp:connect tools:read",
+	}
+}
+
+func newTestAuthenticator(t *testing.T, keys *testKeys) *Authenticator {
+	t.Helper()
+	ks, err := NewKeySet(keys.jwks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(ks, Config{
+		Issuer:         "https://idp.example.com",
+		Audience:       "mcp-servers",
+		RequiredScopes: []string{"mcp:connect"},
+		ScopeMethods: map[string][]string{
+			"tools:read":  {"tools/list"},
+			"tools:write": {"tools/*"},
+		},
+	})
+}
+
+func TestValidate_Algorithms(t *testing.T) {
+	keys := newTestKeys(t)
+	a := newTestAuthenticator(t, keys)
+
+	for _, tok := range []string{
+		sign(t, "RS256", "rsa-1", keys.rsa, validClaims()),
+		sign(t, "ES256", "ec-1", keys.ec, validClaims()),
+		sign(t, "EdDSA", "ed-1", keys.ed, validClaims()),
+	} {
+		claims, err := a.Validate(tok)
+		if err != nil {
+			t.Fatalf("expected token to validate: %v", err)
+		}
+		if claims.Subject != "agent-42" || !claims.HasScope("tools:read") {
+			t.Errorf("unexpected claims: %+v", claims)
+		}
+	}
+}
+
+func TestValidate_Rejections(t *testing.T) {
+	keys := newTestKeys(t)
+	a := newTestAuthenticator(t, keys)
+
+	tests := map[string]func(c map[string]any) string{
+		"wrong issuer":   func(c map[string]any) string { c["iss"] = "evil"; return sign(t, "EdDSA", "ed-1", keys.ed, c) },
+		"wrong audience": func(c map[string]any) string { c["aud"] = "other"; return sign(t, "EdDSA", "ed-1", keys.ed, c) },
+		"expired": func(c map[string]any) string {
+			c["exp"] = time.Now().Add(-time.Minute).Unix()
+			return sign(t, "EdDSA", "ed-1", keys.ed, c)
+		},
+		"no expiry":    func(c map[string]any) string { delete(c, "exp"); return sign(t, "EdDSA", "ed-1", keys.ed, c) },
+		"unknown key":  func(c map[string]any) string { return sign(t, "EdDSA", "ed-2", keys.ed, c) },
+		"alg mismatch": func(c map[string]any) string { return sign(t, "RS256", "ed-1", keys.rsa, c) },
+		"alg none":     func(c map[string]any) string { return sign(t, "none", "ed-1", keys.ed, c) },
+		"tampered claims": func(c map[string]any) string {
+			tok := sign(t, "EdDSA", "ed-1", keys.ed, c)
+			return tok[:len(tok)-4] + "AAAA"
+		},
+	}
+	for name, tokenFn := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := a.Validate(tokenFn(validClaims())); err == nil {
+				t.Error("expected validation to fail")
+			}
+		})
+	}
+}
+
+func TestAuthenticate_Metadata(t *testing.T) {
+	keys := newTestKeys(t)
+	a := newTestAuthenticator(t, keys)
+
+	if _, err := a.Authenticate(context.Background()); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected Unauthenticated without metadata, got %v", err)
+	}
+
+	c := validClaims()
+	c["scope"] = "tools:read"
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+sign(t, "EdDSA", "ed-1", keys.ed, c)))
+	if _, err := a.Authenticate(ctx); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("expected PermissionDenied without required scope, got %v", err)
+	}
+
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+sign(t, "EdDSA", "ed-1", keys.ed, validClaims())))
+	claims, err := a.Authenticate(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ClaimsFromContext(WithClaims(ctx, claims)) != claims {
+		t.Error("expected claims to round-trip through the context")
+	}
+}
+
+func TestAuthorize_ScopeMethods(t *testing.T) {
+	keys := newTestKeys(t)
+	a := newTestAuthenticator(t, keys)
+	claims, err := a.Validate(sign(t, "EdDSA", "ed-1", keys.ed, validClaims()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for method, allowed := range map[string]bool{
+		"initialize":                true,
+		"notifications/initialized": true,
+		"tools/list":                true,
+		"tools/call":                false,
+		"resources/read":            false,
+	} {
+		if err := a.Authorize(claims, method); (err == nil) != allowed {
+			t.Errorf("Authorize(%q) = %v, want allowed=%v", method, err, allowed)
+		}
+	}
+
+	claims.Scopes = append(claims.Scopes, "tools:write")
+	if err := a.Authorize(claims, "tools/call"); err != nil {
+		t.Errorf("expected tools:write to allow tools/call, got %v", err)
+	}
+}
+
+func TestFileKeySet_ReloadsOnUnknownKey(t *testing.T) {
+	first, second := newTestKeys(t), newTestKeys(t)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, first.jwks, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ks, err := NewFileKeySet(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := []byte(`{"keys":[{"kty":"OKP","kid":"ed-2","crv":"Ed25519","x":"` +
+		b64.EncodeToString(second.ed.Public().(ed25519.PublicKey)) + `"}]}`)
+	if err := os.WriteFile(path, rotated, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ks.lastReload = time.Time{}
+	ks.modTime = time.Time{}
+
+	a := New(ks, Config{})
+	if _, err := a.Validate(sign(t, "EdDSA", "ed-2", second.ed, validClaims())); err != nil {
+		t.Errorf("expected the rotated key to be picked up, got %v", err)
+	}
+}
+
+func TestKeySet_SkipsUnsupportedKeys(t *testing.T) {
+	keys := newTestKeys(t)
+	ed := b64.EncodeToString(keys.ed.Public().(ed25519.PublicKey))
+	var skipped []error
+	ks, err := NewKeySet([]byte(`{"keys":[
+		{"kty":"oct","kid":"hmac-1","k":"c2VjcmV0"},
+		{"kty":"RSA","kid":"rsa-oaep","alg":"RSA-OAEP","n":"AQAB","e":"AQAB"},
+		{"kty":"OKP","kid":"ed-1","crv":"Ed25519","x":"`+ed+`"}
+	]}`), WithErrorHandler(func(err error) { skipped = append(skipped, err) }))
+	if err != nil {
+		t.Fatalf("expected unsupported keys to be skipped, got %v", err)
+	}
+	if len(skipped) != 2 {
+		t.Errorf("expected the skipped keys to be reported, got %v", skipped)
+	}
+	if _, err := New(ks, Config{}).Validate(sign(t, "EdDSA", "ed-1", keys.ed, validClaims())); err != nil {
+		t.Errorf("expected the supported key to be usable, got %v", err)
+	}
+
+	if err := ks.Update([]byte(`{"keys":[{"kty":"oct","kid":"hmac-2","k":"c2VjcmV0"}]}`)); err == nil {
+		t.Error("expected a JWKS without usable keys to be rejected")
+	}
+	if _, ok := ks.lookup("ed-1"); !ok {
+		t.Error("expected a rejected update to keep the previous keys")
+	}
+}
+
+func TestKeySet_RejectsInvalidRSAExponents(t *testing.T) {
+	keys := newTestKeys(t)
+	n := b64.EncodeToString(keys.rsa.N.Bytes())
+	for _, e := range [][]byte{{1}, {2}, {1, 0, 0}, {0x80, 0, 0, 1}} {
+		jwks := fmt.Sprintf(`{"keys":[{"kty":"RSA","kid":"rsa-1","n":%q,"e":%q}]}`, n, b64.EncodeToString(e))
+		if _, err := NewKeySet([]byte(jwks)); err == nil {
+			t.Errorf("expected exponent %x to be rejected", e)
+		}
+	}
+}
+
+func TestValidate_KeyAlgorithmMustMatch(t *testing.T) {
+	keys := newTestKeys(t)
+	n, e := b64.EncodeToString(keys.rsa.N.Bytes()), b64.EncodeToString([]byte{1, 0, 1})
+	for alg, ok := range map[string]bool{"RS256": true, "RS384": false, "PS256": false} {
+		ks, err := NewKeySet([]byte(fmt.Sprintf(`{"keys":[{"kty":"RSA","kid":"rsa-1","alg":%q,"n":%q,"e":%q}]}`, alg, n, e)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = New(ks, Config{}).Validate(sign(t, "RS256", "rsa-1", keys.rsa, validClaims()))
+		if ok != (err == nil) {
+			t.Errorf("RS256 token with a %s key: got %v", alg, err)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package jwtauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+)
+
+// minFileReload is the minimum time between reloads of a JWKS file
+// triggered by tokens signed with an unknown key ID
+const minFileReload = 5 * time.Second
+
+// KeySet holds the public keys trusted to sign tokens. Keys can be replaced
+// at any time to follow key rotation.
+type KeySet struct {
+	mu   sync.RWMutex
+	keys map[string]signingKey
+
+	file       string
+	modTime    time.Time
+	lastReload time.Time
+	onError    func(error)
+}
+
+// signingKey is a trusted key, restricted to the algorithm its JWK names
+type signingKey struct {
+	key crypto.PublicKey
+	alg string
+}
+
+type KeySetOption func(*KeySet)
+
+// WithErrorHandler sets the handler for the keys which Update skips, and for
+// the failed reloads of Watch. Without it, skipped keys are ignored and
+// failed reloads written to stderr.
+func WithErrorHandler(handler func(error)) KeySetOption {
+	return func(ks *KeySet) {
+		ks.onError = handler
+	}
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// NewKeySet creates a KeySet from a JWKS document
+func NewKeySet(jwks []byte, opts ...KeySetOption) (*KeySet, error) {
+	ks := &KeySet{}
+	for _, opt := range opts {
+		opt(ks)
+	}
+	if err := ks.Update(jwks); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// NewFileKeySet creates a KeySet from a JWKS file. The file is reloaded by
+// Watch, and whenever a token references an unknown key ID.
+func NewFileKeySet(path string, opts ...KeySetOption) (*KeySet, error) {
+	ks := &KeySet{file: path}
+	for _, opt := range opts {
+		opt(ks)
+	}
+	if err := ks.Reload(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// Update replaces all keys with the keys of a JWKS document. Keys of an
+// unsupported type or algorithm are skipped, so that an identity provider
+// publishing one does not hold up the rotation of the others, unless no
+// usable key remains.
+func (ks *KeySet) Update(jwks []byte) error {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(jwks, &doc); err != nil {
+		return fmt.Errorf("parse JWKS: %w", err)
+	}
+
+	keys := make(map[string]signingKey, len(doc.Keys))
+	var skipped error
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			skipped = fmt.Errorf("skipping JWK %q: %w", k.Kid, err)
+			if ks.onError != nil {
+				ks.onError(skipped)
+			}
+			continue
+		}
+		keys[k.Kid] = signingKey{key: key, alg: k.Alg}
+	}
+	if len(keys) == 0 && skipped != nil {
+		return fmt.Errorf("no usable key in JWKS: %w", skipped)
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys = keys
+	return nil
+}
+
+// Add adds or replaces a single in-process key
+func (ks *KeySet) Add(kid string, key crypto.PublicKey) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if ks.keys == nil {
+		ks.keys = make(map[string]signingKey)
+	}
+	ks.keys[kid] = signingKey{key: key}
+}
+
+// Remove removes a key, e.g. once it has been rotated out
+func (ks *KeySet) Remove(kid string) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	delete(ks.keys, kid)
+}
+
+// Reload reads the JWKS file again if it has changed
+func (ks *KeySet) Reload() error {
+	if ks.file == "" {
+		return nil
+	}
+	fi, err := os.Stat(ks.file)
+	if err != nil {
+		return err
+	}
+
+	ks.mu.Lock()
+	ks.lastReload = time.Now()
+	unchanged := ks.keys != nil && fi.ModTime().Equal(ks.modTime)
+	ks.mu.Unlock()
+	if unchanged {
+		return nil
+	}
+
+	b, err := os.ReadFile(ks.file)
+	if err != nil {
+		return err
+	}
+	if err := ks.Update(b); err != nil {
+		return err
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.modTime = fi.ModTime()
+	return nil
+}
+
+// Watch reloads the JWKS file at the given interval until the context is
+// cancelled. Failed reloads keep the previous keys.
+func (ks *KeySet) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := ks.Reload(); err != nil {
+				if ks.onError != nil {
+					ks.onError(err)
+				} else {
+					fmt.Fprintf(os.Stderr, "JWKS reload failed: %v\n", err)
+				}
+			}
+		}
+	}
+}
+
+// lookup returns the key for kid. Tokens without a kid are accepted when the
+// set holds exactly one key.
+func (ks *KeySet) lookup(kid string) (signingKey, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	if kid == "" && len(ks.keys) == 1 {
+		for _, k := range ks.keys {
+			return k, true
+		}
+	}
+	k, ok := ks.keys[kid]
+	return k, ok
+}
+
+// key returns the key for kid, reloading the JWKS file once if the key is
+// unknown, as the token may have been signed with a freshly rotated key.
+func (ks *KeySet) key(kid string) (signingKey, error) {
+	if k, ok := ks.lookup(kid); ok {
+		return k, nil
+	}
+
+	ks.mu.RLock()
+	canReload := ks.file != "" && time.Since(ks.lastReload) > minFileReload
+	ks.mu.RUnlock()
+	if canReload {
+		if err := ks.Reload(); err == nil {
+			if k, ok := ks.lookup(kid); ok {
+				return k, nil
+			}
+		}
+	}
+	return signingKey{}, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	if k.Alg != "" {
+		if _, err := algorithmHash(k.Alg); err != nil {
+			return nil, err
+		}
+	}
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		// crypto/rsa only verifies with odd exponents in [3, 2^31-1]
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 || e.Bit(0) == 0 {
+			return nil, fmt.Errorf("invalid RSA exponent %v", e)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on curve %s", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key size %d", len(x))
+		}
+		return ed25519.PublicKey(x), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
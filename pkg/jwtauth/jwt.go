@@ -0,0 +1,224 @@
+package jwtauth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Claims are the verified claims of a token
+type Claims struct {
+	Issuer    string
+	Subject   string
+	Audience  []string
+	ExpiresAt time.Time
+	NotBefore time.Time
+	IssuedAt  time.Time
+	Scopes    []string
+	// Raw holds every claim of the token, including custom ones
+	Raw map[string]any
+}
+
+// HasScope reports whether the token was granted the scope
+func (c *Claims) HasScope(scope string) bool {
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type header struct {
+	Alg  string   `json:"alg"`
+	Kid  string   `json:"kid"`
+	Crit []string `json:"crit"`
+}
+
+// parse verifies the signature of a compact JWS token and decodes its claims.
+// Registered claims are decoded but not validated.
+func parse(token string, keys *KeySet) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, fmt.Errorf("malformed token header: %w", err)
+	}
+	if len(h.Crit) > 0 {
+		return nil, fmt.Errorf("unsupported critical header parameters %v", h.Crit)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token signature: %w", err)
+	}
+	key, err := keys.key(h.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if key.alg != "" && h.Alg != key.alg {
+		return nil, fmt.Errorf("token algorithm %q does not match the key's %q", h.Alg, key.alg)
+	}
+	if err := verify(h.Alg, key.key, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, err
+	}
+
+	raw := map[string]any{}
+	if err := decodeSegment(parts[1], &raw); err != nil {
+		return nil, fmt.Errorf("malformed token claims: %w", err)
+	}
+	return newClaims(raw)
+}
+
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+func newClaims(raw map[string]any) (*Claims, error) {
+	c := &Claims{Raw: raw}
+	c.Issuer, _ = raw["iss"].(string)
+	c.Subject, _ = raw["sub"].(string)
+
+	switch aud := raw["aud"].(type) {
+	case string:
+		c.Audience = []string{aud}
+	case []any:
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				c.Audience = append(c.Audience, s)
+			}
+		}
+	}
+
+	for claim, t := range map[string]*time.Time{"exp": &c.ExpiresAt, "nbf": &c.NotBefore, "iat": &c.IssuedAt} {
+		v, ok := raw[claim]
+		if !ok {
+			continue
+		}
+		n, ok := v.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("claim %q is not a number", claim)
+		}
+		secs, err := n.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("claim %q: %w", claim, err)
+		}
+		*t = time.Unix(0, int64(secs*float64(time.Second)))
+	}
+
+	// "scope" is a space separated string (RFC 8693), some issuers use a "scp" array
+	if scope, ok := raw["scope"].(string); ok {
+		c.Scopes = strings.Fields(scope)
+	}
+	if scp, ok := raw["scp"].([]any); ok {
+		for _, s := range scp {
+			if s, ok := s.(string); ok {
+				c.Scopes = append(c.Scopes, s)
+			}
+		}
+	}
+	return c, nil
+}
+
+// algorithmHash returns the hash of a signing algorithm, which is zero for
+// EdDSA, or an error if the algorithm is not supported
+func algorithmHash(alg string) (crypto.Hash, error) {
+	switch alg {
+	case "RS256", "PS256", "ES256":
+		return crypto.SHA256, nil
+	case "RS384", "PS384", "ES384":
+		return crypto.SHA384, nil
+	case "RS512", "PS512", "ES512":
+		return crypto.SHA512, nil
+	case "EdDSA":
+		return 0, nil
+	}
+	return 0, fmt.Errorf("unsupported signing algorithm %q", alg)
+}
+
+func verify(alg string, key crypto.PublicKey, signed, sig []byte) error {
+	hash, err := algorithmHash(alg)
+	if err != nil {
+		return err
+	}
+
+	var digest []byte
+	if hash != 0 {
+		h := hash.New()
+		h.Write(signed)
+		digest = h.Sum(nil)
+	}
+
+	invalid := errors.New("invalid token signature")
+	switch alg[:2] {
+	case "RS", "PS":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("key type %T cannot verify %s", key, alg)
+		}
+		var err error
+		if alg[0] == 'R' {
+			err = rsa.VerifyPKCS1v15(pub, hash, digest, sig)
+		} else {
+			err = rsa.VerifyPSS(pub, hash, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+		if err != nil {
+			return invalid
+		}
+	case "ES":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("key type %T cannot verify %s", key, alg)
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size || hash.Size()*8 != ecdsaHashBits(pub) {
+			return invalid
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return invalid
+		}
+	case "Ed":
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return fmt.Errorf("key type %T cannot verify %s", key, alg)
+		}
+		if !ed25519.Verify(pub, signed, sig) {
+			return invalid
+		}
+	}
+	return nil
+}
+
+// ecdsaHashBits returns the hash size mandated for the key's curve by RFC 7518
+func ecdsaHashBits(pub *ecdsa.PublicKey) int {
+	switch pub.Curve.Params().BitSize {
+	case 256:
+		return 256
+	case 384:
+		return 384
+	default:
+		return 512
+	}
+}
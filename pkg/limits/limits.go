@@ -0,0 +1,185 @@
+// Package limits bounds the size and complexity of the JSON carried in
+// GenericJSONRPCMessage frames, answering violations with JSON-RPC errors
+// instead of tearing down the stream.
+//
+// Frames larger than grpc.MaxRecvMsgSize are still rejected by gRPC itself
+// and end the stream, so MaxParamsBytes should stay below that setting.
+package limits
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/message"
+	pb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/jsonrpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// JSON-RPC error codes used for limit violations
+const (
+	ErrorCodeParams = -32602 // Invalid params
+	ErrorCodeResult = -32603 // Internal error
+)
+
+// Limits applied to incoming params and outgoing results. A zero field
+// disables the corresponding limit, and a nil *Limits disables all of them.
+type Limits struct {
+	// MaxParamsBytes bounds the encoded size of incoming params
+	MaxParamsBytes int
+	// MaxDepth bounds the nesting of objects and arrays in incoming params
+	MaxDepth int
+	// MaxArrayLength bounds the number of elements of any array in incoming params
+	MaxArrayLength int
+	// MaxObjectKeys bounds the number of keys of any object in incoming params
+	MaxObjectKeys int
+
+	// MaxResultBytes bounds the encoded size of outgoing results
+	MaxResultBytes int
+	// TruncateResults shortens the text content of oversized tools/call
+	// results instead of replacing them with an error
+	TruncateResults bool
+}
+
+// Violation describes an exceeded limit
+type Violation struct {
+	Limit  string
+	Max    int
+	Actual int
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("%s limit exceeded: %d > %d", v.Limit, v.Actual, v.Max)
+}
+
+// Data returns the details to be sent in the JSON-RPC error's data field
+func (v *Violation) Data() map[string]any {
+	return map[string]any{
+		"limit":  v.Limit,
+		"max":    v.Max,
+		"actual": v.Actual,
+	}
+}
+
+// CheckParams validates params against the incoming limits
+func (l *Limits) CheckParams(params *structpb.Struct) error {
+	if l == nil || params == nil {
+		return nil
+	}
+	if l.MaxParamsBytes > 0 {
+		if size := proto.Size(params); size > l.MaxParamsBytes {
+			return &Violation{Limit: "params_bytes", Max: l.MaxParamsBytes, Actual: size}
+		}
+	}
+	return l.checkValue(structpb.NewStructValue(params), 1)
+}
+
+func (l *Limits) checkValue(v *structpb.Value, depth int) error {
+	switch kind := v.GetKind().(type) {
+	case *structpb.Value_StructValue:
+		if l.MaxDepth > 0 && depth > l.MaxDepth {
+			return &Violation{Limit: "depth", Max: l.MaxDepth, Actual: depth}
+		}
+		fields := kind.StructValue.GetFields()
+		if l.MaxObjectKeys > 0 && len(fields) > l.MaxObjectKeys {
+			return &Violation{Limit: "object_keys", Max: l.MaxObjectKeys, Actual: len(fields)}
+		}
+		for _, child := range fields {
+			if err := l.checkValue(child, depth+1); err != nil {
+				return err
+			}
+		}
+	case *structpb.Value_ListValue:
+		if l.MaxDepth > 0 && depth > l.MaxDepth {
+			return &Violation{Limit: "depth", Max: l.MaxDepth, Actual: depth}
+		}
+		values := kind.ListValue.GetValues()
+		if l.MaxArrayLength > 0 && len(values) > l.MaxArrayLength {
+			return &Violation{Limit: "array_length", Max: l.MaxArrayLength, Actual: len(values)}
+		}
+		for _, child := range values {
+			if err := l.checkValue(child, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Request checks an incoming message. It returns the error response to send
+// back for a request which violates a limit, or nil if it may be handled.
+// Violating notifications and responses are reported through the error.
+func (l *Limits) Request(m *pb.GenericJSONRPCMessage) (*pb.GenericJSONRPCMessage, error) {
+	err := l.CheckParams(m.GetParams())
+	if err == nil {
+		err = l.CheckParams(m.GetResult())
+	}
+	if err == nil {
+		return nil, nil
+	}
+	v := err.(*Violation)
+	if !message.IsRequest(m) {
+		return nil, err
+	}
+	return message.NewError(m.TypedId, ErrorCodeParams, v.Error(), v.Data()), err
+}
+
+// Response applies the outgoing limits to a message about to be sent. It
+// returns the message itself, a truncated copy, or an error response.
+func (l *Limits) Response(m *pb.GenericJSONRPCMessage) *pb.GenericJSONRPCMessage {
+	if l == nil || l.MaxResultBytes <= 0 || m.GetResult() == nil {
+		return m
+	}
+	size := proto.Size(m.Result)
+	if size <= l.MaxResultBytes {
+		return m
+	}
+
+	if l.TruncateResults {
+		if result, ok := truncate(m.Result, l.MaxResultBytes); ok {
+			out := proto.Clone(m).(*pb.GenericJSONRPCMessage)
+			out.Result = result
+			return out
+		}
+	}
+
+	v := &Violation{Limit: "result_bytes", Max: l.MaxResultBytes, Actual: size}
+	return message.NewError(m.TypedId, ErrorCodeResult, v.Error(), v.Data())
+}
+
+const truncationMarker = "\n[truncated %d bytes]"
+
+// truncate shortens the text items of a tools/call result's content, last
+// item first, until the result fits into max bytes.
+func truncate(result *structpb.Struct, max int) (*structpb.Struct, bool) {
+	result = proto.Clone(result).(*structpb.Struct)
+	content := result.GetFields()["content"].GetListValue().GetValues()
+
+	for i := len(content) - 1; i >= 0; i-- {
+		excess := proto.Size(result) - max
+		if excess <= 0 {
+			return result, true
+		}
+
+		item := content[i].GetStructValue().GetFields()
+		if item["type"].GetStringValue() != "text" {
+			continue
+		}
+		text := item["text"].GetStringValue()
+		cut := excess + len(fmt.Sprintf(truncationMarker, len(text)))
+		keep := len(text) - cut
+		if keep < 0 {
+			keep = 0
+		}
+		for keep > 0 && !utf8.RuneStart(text[keep]) {
+			keep--
+		}
+		var b strings.Builder
+		b.WriteString(text[:keep])
+		fmt.Fprintf(&b, truncationMarker, len(text)-keep)
+		item["text"] = structpb.NewStringValue(b.String())
+	}
+
+	return result, proto.Size(result) <= max
+}
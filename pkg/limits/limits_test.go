@@ -0,0 +1,101 @@
+package limits
+
+import (
+	"strings"
+	"testing"
+
+	pb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/jsonrpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func mustStruct(t *testing.T, m map[string]any) *structpb.Struct {
+	t.Helper()
+	s, err := structpb.NewStruct(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestCheckParams(t *testing.T) {
+	l := &Limits{MaxDepth: 3, MaxArrayLength: 2, MaxObjectKeys: 3}
+
+	tests := []struct {
+		name   string
+		params map[string]any
+		limit  string
+	}{
+		{"within limits", map[string]any{"a": map[string]any{"b": []any{1.0, 2.0}}}, ""},
+		{"too deep", map[string]any{"a": map[string]any{"b": map[string]any{"c": map[string]any{}}}}, "depth"},
+		{"array too long", map[string]any{"a": []any{1.0, 2.0, 3.0}}, "array_length"},
+		{"too many keys", map[string]any{"a": 1.0, "b": 2.0, "c": 3.0, "d": 4.0}, "object_keys"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := l.CheckParams(mustStruct(t, tt.params))
+			if tt.limit == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			v, ok := err.(*Violation)
+			if !ok || v.Limit != tt.limit {
+				t.Errorf("expected %s violation, got %v", tt.limit, err)
+			}
+		})
+	}
+}
+
+func TestRequest_ErrorResponseForRequests(t *testing.T) {
+	l := &Limits{MaxParamsBytes: 16}
+	params := mustStruct(t, map[string]any{"arguments": strings.Repeat("x", 64)})
+
+	req := &pb.GenericJSONRPCMessage{TypedId: &pb.ID{Kind: &pb.ID_Num{Num: 3}}, Method: "tools/call", Params: params}
+	resp, err := l.Request(req)
+	if err == nil || resp == nil {
+		t.Fatalf("expected an error response, got %v, %v", resp, err)
+	}
+	if resp.Error.Code != ErrorCodeParams || resp.TypedId.GetNum() != 3 {
+		t.Errorf("unexpected error response: %v", resp)
+	}
+
+	notification := &pb.GenericJSONRPCMessage{Method: "notifications/progress", Params: params}
+	if resp, err := l.Request(notification); err == nil || resp != nil {
+		t.Errorf("expected notifications to be dropped without a response, got %v, %v", resp, err)
+	}
+}
+
+func TestResponse(t *testing.T) {
+	result := mustStruct(t, map[string]any{
+		"content": []any{
+			map[string]any{"type": "text", "text": "header"},
+			map[string]any{"type": "text", "text": strings.Repeat("é", 500)},
+		},
+	})
+	msg := &pb.GenericJSONRPCMessage{TypedId: &pb.ID{Kind: &pb.ID_Num{Num: 1}}, Result: result}
+
+	errResp := (&Limits{MaxResultBytes: 200}).Response(msg)
+	if errResp.GetError().GetCode() != ErrorCodeResult {
+		t.Errorf("expected an error response, got %v", errResp)
+	}
+
+	truncated := (&Limits{MaxResultBytes: 200, TruncateResults: true}).Response(msg)
+	if truncated.GetError() != nil {
+		t.Fatalf("expected a truncated result, got %v", truncated.GetError())
+	}
+	if size := proto.Size(truncated.Result); size > 200 {
+		t.Errorf("truncated result is still %d bytes", size)
+	}
+	content := truncated.Result.Fields["content"].GetListValue().Values
+	if text := content[0].GetStructValue().Fields["text"].GetStringValue(); text != "header" {
+		t.Errorf("expected the first item to be kept, got %q", text)
+	}
+	if text := content[1].GetStructValue().Fields["text"].GetStringValue(); !strings.Contains(text, "[truncated ") {
+		t.Errorf("expected a truncation marker, got %q", text)
+	}
+	if proto.Size(msg.Result) <= 200 {
+		t.Error("the original message must not be modified")
+	}
+}
@@ -0,0 +1,278 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/codec"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/message"
+	pb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/jsonrpc"
+	"google.golang.org/grpc"
+)
+
+// GrpcClient implements the client-side transport of mark3labs/mcp-go over
+// a JSONRPCService Transport stream, for use with client.NewClient:
+//
+//	conn, err := grpc.NewClient("localhost:50051", grpc.WithTransportCredentials(insecure.NewCredentials()))
+//	c := client.NewClient(grpctransport.NewGrpcClient(conn))
+//	err = c.Start(ctx)
+//	_, err = c.Initialize(ctx, mcp.InitializeRequest{...})
+type GrpcClient struct {
+	client   pb.JSONRPCServiceClient
+	callOpts []grpc.CallOption
+
+	stream pb.JSONRPCService_TransportClient
+	cancel context.CancelFunc
+	// sendMu serializes stream.Send, as requests are sent concurrently
+	sendMu sync.Mutex
+
+	mu        sync.Mutex
+	responses map[string]chan *transport.JSONRPCResponse
+	// done is closed with err set once the stream has ended
+	done chan struct{}
+	err  error
+
+	notifyMu       sync.RWMutex
+	onNotification func(mcp.JSONRPCNotification)
+	onError        func(error)
+}
+
+var _ transport.Interface = (*GrpcClient)(nil)
+
+type GrpcClientOption func(*GrpcClient)
+
+// WithCallOpts sets the options of the Transport call, e.g. per-RPC
+// credentials or compression
+func WithCallOpts(opts ...grpc.CallOption) GrpcClientOption {
+	return func(c *GrpcClient) {
+		c.callOpts = opts
+	}
+}
+
+// WithErrorHandler sets the handler for the messages from the server which
+// could not be handled, which are otherwise dropped silently. Nothing is
+// written to stdout, as it is the protocol channel of stdio-hosted apps.
+func WithErrorHandler(handler func(error)) GrpcClientOption {
+	return func(c *GrpcClient) {
+		c.onError = handler
+	}
+}
+
+var (
+	errNotStarted = errors.New("grpc client not started")
+	errClosed     = errors.New("grpc client closed")
+)
+
+// NewGrpcClient creates a client transport which opens its stream on conn.
+// The connection is owned by the caller, and is not closed by Close.
+func NewGrpcClient(conn grpc.ClientConnInterface, opts ...GrpcClientOption) *GrpcClient {
+	c := &GrpcClient{
+		client:    pb.NewJSONRPCServiceClient(conn),
+		responses: make(map[string]chan *transport.JSONRPCResponse),
+		done:      make(chan struct{}),
+		onError:   func(error) {},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Start opens the stream. It stays open until Close rather than until ctx is
+// done, but keeps the values of ctx such as its outgoing metadata.
+func (c *GrpcClient) Start(ctx context.Context) error {
+	if c.stream != nil {
+		return errors.New("grpc client already started")
+	}
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stream, err := c.client.Transport(ctx, c.callOpts...)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("failed to open stream: %w", err)
+	}
+	c.stream, c.cancel = stream, cancel
+	go c.receive()
+	return nil
+}
+
+// SendRequest sends the request and waits for its response. If ctx is done
+// first, the server is told with notifications/cancelled.
+func (c *GrpcClient) SendRequest(ctx context.Context, request transport.JSONRPCRequest) (*transport.JSONRPCResponse, error) {
+	if c.stream == nil {
+		return nil, errNotStarted
+	}
+	msg, err := toMessage(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert request: %w", err)
+	}
+
+	key := request.ID.String()
+	ch := make(chan *transport.JSONRPCResponse, 1)
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return nil, c.err
+	}
+	c.responses[key] = ch
+	c.mu.Unlock()
+
+	if err := c.send(msg); err != nil {
+		c.forget(key)
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-c.done:
+		c.forget(key)
+		return nil, c.err
+	case <-ctx.Done():
+		c.forget(key)
+		// Best effort, the caller has stopped waiting either way
+		_ = c.send(message.NewCancelled(msg.TypedId, ctx.Err().Error()))
+		return nil, ctx.Err()
+	}
+}
+
+// SendNotification sends the notification to the server
+func (c *GrpcClient) SendNotification(ctx context.Context, notification mcp.JSONRPCNotification) error {
+	if c.stream == nil {
+		return errNotStarted
+	}
+	msg, err := toMessage(notification)
+	if err != nil {
+		return fmt.Errorf("failed to convert notification: %w", err)
+	}
+	if err := c.send(msg); err != nil {
+		return fmt.Errorf("failed to send notification: %w", err)
+	}
+	return nil
+}
+
+// SetNotificationHandler sets the handler of the notifications from the
+// server. Notifications received before it is set are discarded.
+func (c *GrpcClient) SetNotificationHandler(handler func(notification mcp.JSONRPCNotification)) {
+	c.notifyMu.Lock()
+	defer c.notifyMu.Unlock()
+	c.onNotification = handler
+}
+
+// Close ends the stream. Requests still waiting for their response fail.
+func (c *GrpcClient) Close() error {
+	if c.stream == nil || !c.fail(errClosed) {
+		return nil
+	}
+	c.sendMu.Lock()
+	_ = c.stream.CloseSend()
+	c.sendMu.Unlock()
+	c.cancel()
+	return nil
+}
+
+func (c *GrpcClient) send(msg *pb.GenericJSONRPCMessage) error {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	select {
+	case <-c.done:
+		return c.err
+	default:
+	}
+	return c.stream.Send(msg)
+}
+
+func (c *GrpcClient) forget(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.responses, key)
+}
+
+// fail ends the client with err, and reports whether it was still running
+func (c *GrpcClient) fail(err error) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return false
+	}
+	if err == io.EOF {
+		err = errors.New("the server closed the stream")
+	}
+	c.err = err
+	close(c.done)
+	return true
+}
+
+func (c *GrpcClient) receive() {
+	for {
+		msg, err := c.stream.Recv()
+		if err != nil {
+			c.fail(err)
+			return
+		}
+		switch {
+		case message.IsResponse(msg):
+			c.deliver(msg)
+		case message.IsNotification(msg):
+			c.notify(msg)
+		case message.IsRequest(msg):
+			// The mark3labs client has no handlers for requests from the
+			// server, like sampling
+			resp := message.NewError(msg.TypedId, mcp.METHOD_NOT_FOUND, "client does not support "+msg.Method, nil)
+			if err := c.send(resp); err != nil {
+				c.onError(fmt.Errorf("failed to decline request: %w", err))
+			}
+		}
+	}
+}
+
+func (c *GrpcClient) deliver(msg *pb.GenericJSONRPCMessage) {
+	var resp transport.JSONRPCResponse
+	if err := fromMessage(msg, &resp); err != nil {
+		c.onError(fmt.Errorf("dropping response: %w", err))
+		return
+	}
+	key := resp.ID.String()
+	c.mu.Lock()
+	ch, ok := c.responses[key]
+	delete(c.responses, key)
+	c.mu.Unlock()
+	if ok {
+		ch <- &resp
+	}
+}
+
+func (c *GrpcClient) notify(msg *pb.GenericJSONRPCMessage) {
+	var notification mcp.JSONRPCNotification
+	if err := fromMessage(msg, &notification); err != nil {
+		c.onError(fmt.Errorf("dropping notification: %w", err))
+		return
+	}
+	c.notifyMu.RLock()
+	defer c.notifyMu.RUnlock()
+	if c.onNotification != nil {
+		c.onNotification(notification)
+	}
+}
+
+// toMessage converts a JSON-RPC message of mcp-go to a frame
+func toMessage(v any) (*pb.GenericJSONRPCMessage, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return codec.Decode(b)
+}
+
+// fromMessage converts a frame to a JSON-RPC message of mcp-go
+func fromMessage(m *pb.GenericJSONRPCMessage, v any) error {
+	b, err := codec.Encode(m)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,86 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	mcpsrv "github.com/mark3labs/mcp-go/server"
+	pb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/jsonrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestGrpcClient(t *testing.T) {
+	s := mcpsrv.NewMCPServer("test", "1.0.0")
+	s.AddTool(mcp.NewTool("hello", mcp.WithString("name", mcp.Required())), func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name, err := req.RequireString("name")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText("Hello, " + name + "!"), nil
+	})
+
+	lis := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer()
+	pb.RegisterJSONRPCServiceServer(gs, NewGrpcServer(s))
+	go gs.Serve(lis)
+	defer gs.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	c := client.NewClient(NewGrpcClient(conn))
+	if err := c.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	init := mcp.InitializeRequest{}
+	init.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	init.Params.ClientInfo = mcp.Implementation{Name: "test", Version: "1.0.0"}
+	info, err := c.Initialize(ctx, init)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ServerInfo.Name != "test" {
+		t.Errorf("expected server name 'test', got '%s'", info.ServerInfo.Name)
+	}
+
+	tools, err := c.ListTools(ctx, mcp.ListToolsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tools.Tools) != 1 || tools.Tools[0].Name != "hello" {
+		t.Errorf("expected the tool 'hello', got %+v", tools.Tools)
+	}
+
+	call := mcp.CallToolRequest{}
+	call.Params.Name = "hello"
+	call.Params.Arguments = map[string]any{"name": "Ann"}
+	result, err := c.CallTool(ctx, call)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, ok := result.Content[0].(mcp.TextContent); !ok || text.Text != "Hello, Ann!" {
+		t.Errorf("expected 'Hello, Ann!', got %+v", result.Content)
+	}
+
+	if _, err := c.ReadResource(ctx, mcp.ReadResourceRequest{}); err == nil {
+		t.Error("expected an error for a server without resources")
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Ping(ctx); err == nil {
+		t.Error("expected an error after close")
+	}
+}
@@ -5,13 +5,35 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net"
+	"os"
+	"slices"
+	"sync/atomic"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	mcpsrv "github.com/mark3labs/mcp-go/server"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/admin"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/audit"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/codec"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/hooks"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/jwtauth"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/limits"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/message"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/metrics"
+	adminpb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/admin"
 	pb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/jsonrpc"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/ratelimit"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/session"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/timeout"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/tlsreload"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/tracing"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/transcript"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/structpb"
 )
 
@@ -20,13 +42,36 @@ type GrpcServer struct {
 	pb.UnimplementedJSONRPCServiceServer
 	mcpserver *mcpsrv.MCPServer
 	// onMessage func(ctx context.Context, message *transport.BaseJsonRpcMessage)
-	host     string
-	port     int
-	grpcOpts []grpc.ServerOption
+	host       string
+	port       int
+	grpcOpts   []grpc.ServerOption
+	limiter    *ratelimit.Limiter
+	principal  session.PrincipalFunc
+	tlsFiles   *tlsFiles
+	audit      *audit.Logger
+	limits     *limits.Limits
+	auth       *jwtauth.Authenticator
+	metrics    metrics.Recorder
+	tracer     tracing.Tracer
+	admin      *admin.Admin
+	transcript *transcript.Recorder
+	timeouts   *timeout.Config
+	hooks      *hooks.Hooks
+	errLog     *log.Logger
+	// maxConcurrent bounds the requests of a session handled at once
+	maxConcurrent int
 }
 
+// DefaultMaxConcurrentRequests is the number of requests of a session handled
+// at once, unless set with WithMaxConcurrentRequests
+const DefaultMaxConcurrentRequests = 16
+
 type GrpcServerOption func(*GrpcServer)
 
+type tlsFiles struct {
+	cert, key, clientCA string
+}
+
 func WithHost(host string) GrpcServerOption {
 	return func(s *GrpcServer) {
 		s.host = host
@@ -45,29 +90,188 @@ func WithGrpcOpts(opts ...grpc.ServerOption) GrpcServerOption {
 	}
 }
 
+// WithTLS serves TLS using the certificate and key files. If clientCAFile is
+// set, clients must present a certificate signed by one of its CAs. The files
+// are watched and rotated material is picked up without a restart.
+func WithTLS(certFile, keyFile, clientCAFile string) GrpcServerOption {
+	return func(s *GrpcServer) {
+		s.tlsFiles = &tlsFiles{cert: certFile, key: keyFile, clientCA: clientCAFile}
+	}
+}
+
+// WithRateLimiter applies the limiter to every request received on the server.
+// Over-limit requests are answered with a JSON-RPC error carrying a retry hint.
+func WithRateLimiter(l *ratelimit.Limiter) GrpcServerOption {
+	return func(s *GrpcServer) {
+		s.limiter = l
+	}
+}
+
+// WithAuditLogger records an audit event for every tools/call, resources/read
+// and prompts/get request handled by the server.
+func WithAuditLogger(l *audit.Logger) GrpcServerOption {
+	return func(s *GrpcServer) {
+		s.audit = l
+	}
+}
+
+// WithMessageLimits bounds the size and complexity of incoming params and
+// outgoing results. Violating requests are answered with a JSON-RPC error
+// and the stream stays up.
+func WithMessageLimits(l limits.Limits) GrpcServerOption {
+	return func(s *GrpcServer) {
+		s.limits = &l
+	}
+}
+
+// WithAuthenticator requires every stream to present a valid JWT in its
+// "authorization" metadata. The token's subject becomes the principal, its
+// scopes are checked for every request, and its claims are available to
+// handlers through jwtauth.ClaimsFromContext.
+func WithAuthenticator(a *jwtauth.Authenticator) GrpcServerOption {
+	return func(s *GrpcServer) {
+		s.auth = a
+	}
+}
+
+// WithMetrics records MCP-level metrics of the sessions and messages handled
+// by the server, e.g. with metrics.NewPrometheus.
+func WithMetrics(r metrics.Recorder) GrpcServerOption {
+	return func(s *GrpcServer) {
+		s.metrics = r
+	}
+}
+
+// WithTracer starts a span for every request handled by the server. The W3C
+// trace context of the request's "_meta", or else of the stream metadata,
+// becomes its parent. Without a tracer the received trace context is still
+// passed to handlers through tracing.FromContext.
+func WithTracer(t tracing.Tracer) GrpcServerOption {
+	return func(s *GrpcServer) {
+		s.tracer = t
+	}
+}
+
+// WithAdmin serves the MCPAdmin service next to the JSON-RPC service, to
+// inspect the live sessions and cancel requests or terminate sessions. Its
+// calls are authenticated like streams with WithAuthenticator, and denied
+// unless the Admin has an authorizer.
+func WithAdmin(a *admin.Admin) GrpcServerOption {
+	return func(s *GrpcServer) {
+		s.admin = a
+	}
+}
+
+// WithTranscript records every message of every session, e.g. to capture
+// a bug report which can be replayed with the client's replay command.
+func WithTranscript(r *transcript.Recorder) GrpcServerOption {
+	return func(s *GrpcServer) {
+		s.transcript = r
+	}
+}
+
+// WithTimeouts bounds how long request handlers may run. A request which
+// exceeds its budget is answered with a JSON-RPC timeout error and its
+// handler's context is cancelled. Timeouts from a request's "_meta" and the
+// stream's gRPC deadline are honored even without this option.
+func WithTimeouts(c timeout.Config) GrpcServerOption {
+	return func(s *GrpcServer) {
+		s.timeouts = &c
+	}
+}
+
+// WithMaxConcurrentRequests bounds the requests of a session handled at
+// once. Further requests are not read from the stream until a handler
+// returns.
+func WithMaxConcurrentRequests(n int) GrpcServerOption {
+	return func(s *GrpcServer) {
+		s.maxConcurrent = n
+	}
+}
+
+// WithHooks calls the hooks on the lifecycle events of every session, e.g.
+// to attach billing or analytics to the server.
+func WithHooks(h *hooks.Hooks) GrpcServerOption {
+	return func(s *GrpcServer) {
+		s.hooks = h
+	}
+}
+
+// WithPrincipalFunc sets how the authenticated principal of a stream is
+// determined. Defaults to session.DefaultPrincipal.
+func WithPrincipalFunc(fn session.PrincipalFunc) GrpcServerOption {
+	return func(s *GrpcServer) {
+		s.principal = fn
+	}
+}
+
+// WithErrorLogger sets the logger of the errors which cannot be reported to
+// the client. Defaults to a logger writing to stderr; nil discards them.
+func WithErrorLogger(logger *log.Logger) GrpcServerOption {
+	return func(s *GrpcServer) {
+		s.errLog = logger
+	}
+}
+
 // NewGrpcServer creates a new MCP Server with gRPC Transport
 func NewGrpcServer(server *mcpsrv.MCPServer, opts ...GrpcServerOption) *GrpcServer {
 	srv := &GrpcServer{
-		port:      50051,
-		mcpserver: server,
+		port:          50051,
+		metrics:       metrics.Discard,
+		mcpserver:     server,
+		maxConcurrent: DefaultMaxConcurrentRequests,
+		errLog:        log.New(os.Stderr, "", log.LstdFlags),
 	}
 	for _, opt := range opts {
 		opt(srv)
 	}
+	srv.maxConcurrent = max(srv.maxConcurrent, 1)
+	if srv.errLog == nil {
+		srv.errLog = log.New(io.Discard, "", 0)
+	}
 	return srv
 }
 
 type ctxKey string
 
 func (t *GrpcServer) Listen(ctx context.Context) error {
+	grpcOpts := t.grpcOpts
+	if t.tlsFiles != nil {
+		var opts []tlsreload.Option
+		if t.tlsFiles.clientCA != "" {
+			opts = append(opts, tlsreload.WithClientCA(t.tlsFiles.clientCA))
+		}
+		reloader, err := tlsreload.New(t.tlsFiles.cert, t.tlsFiles.key, opts...)
+		if err != nil {
+			return err
+		}
+		go reloader.Watch(ctx)
+		grpcOpts = append(slices.Clone(grpcOpts), grpc.Creds(reloader.Credentials()))
+	}
+
 	lis, err := net.Listen("tcp", fmt.Sprintf("%s:%d", t.host, t.port))
 	if err != nil {
 		return err
 	}
-	grpcServer := grpc.NewServer(t.grpcOpts...)
+	grpcServer := grpc.NewServer(grpcOpts...)
 	reflection.Register(grpcServer)
 
 	pb.RegisterJSONRPCServiceServer(grpcServer, t)
+	if t.admin != nil {
+		// The admin service shares the port, so it is held to the same
+		// authentication as the streams
+		var adminServer adminpb.MCPAdminServer = t.admin
+		if t.auth != nil {
+			adminServer = t.admin.Authenticated(func(ctx context.Context) (context.Context, error) {
+				claims, err := t.auth.Authenticate(ctx)
+				if err != nil {
+					return nil, err
+				}
+				return jwtauth.WithClaims(ctx, claims), nil
+			})
+		}
+		adminpb.RegisterMCPAdminServer(grpcServer, adminServer)
+	}
 
 	return grpcServer.Serve(lis)
 }
@@ -76,15 +280,79 @@ func (t *GrpcServer) Close() error {
 	return nil
 }
 
-func (g *GrpcServer) Transport(stream pb.JSONRPCService_TransportServer) error {
+func (g *GrpcServer) Transport(stream pb.JSONRPCService_TransportServer) (err error) {
 	fmt.Printf("transport started...\n")
 
-	ctx := context.WithValue(context.Background(), ctxKey("stream"), stream)
+	ctx := stream.Context()
+	if g.auth != nil {
+		claims, err := g.auth.Authenticate(ctx)
+		if err != nil {
+			return err
+		}
+		ctx = jwtauth.WithClaims(ctx, claims)
+		ctx = session.WithPrincipal(ctx, claims.Subject)
+	}
 
+	info := session.New(ctx, g.principal)
+	g.metrics.SessionOpened()
+	defer func() {
+		g.metrics.SessionClosed()
+		g.limiter.CloseSession(info.ID)
+	}()
+
+	ctx, terminate := context.WithCancelCause(ctx)
+	defer terminate(nil)
+
+	sess := &grpcSession{
+		info:          info,
+		stream:        stream,
+		fail:          terminate,
+		slots:         make(chan struct{}, g.maxConcurrent),
+		notifications: make(chan mcp.JSONRPCNotification, notificationBuffer),
+	}
+	// The MCP server sends its notifications to registered sessions, and
+	// those of a handler to the session of its context
+	if err := g.mcpserver.RegisterSession(ctx, sess); err != nil {
+		return err
+	}
+	defer g.mcpserver.UnregisterSession(ctx, info.ID)
+	ctx = g.mcpserver.WithContext(ctx, sess)
+	g.hooks.StreamOpened(info)
+	defer func() {
+		g.hooks.StreamClosed(info, err, sess.received.Load(), sess.sent.Load())
+	}()
+	sess.admin = g.admin.Open(info, func(reason string) {
+		terminate(status.Error(codes.Aborted, reason))
+	})
+	defer sess.admin.Close()
+
+	ctx = session.WithInfo(ctx, info)
+	ctx = context.WithValue(ctx, ctxKey("stream"), stream)
+
+	go g.notify(ctx, sess)
+
+	// Receive in the background, so that a terminated session ends its
+	// stream even while blocked in Recv
+	errc := make(chan error, 1)
+	go func() {
+		errc <- g.serve(ctx, sess)
+	}()
+	select {
+	case err = <-errc:
+		return err
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+}
+
+func (g *GrpcServer) serve(ctx context.Context, sess *grpcSession) error {
 	for {
-		ms, err := stream.Recv()
+		ms, err := sess.stream.Recv()
 		if err == io.EOF {
 			fmt.Println("Stream closed by client")
+			// The client may half-close the stream and still wait for
+			// the responses to its outstanding requests
+			sess.pending.Wait()
 			return nil
 		}
 		if err != nil {
@@ -94,23 +362,194 @@ func (g *GrpcServer) Transport(stream pb.JSONRPCService_TransportServer) error {
 		// TODO: debug log the recevied request
 		// fmt.Printf("Received message...: %s\n", ms)
 
-		if baseMsg, err := ToJsonRpcMessage(ms); err != nil {
-			return err
-		} else {
-			jmsg := g.mcpserver.HandleMessage(ctx, baseMsg)
-			pbmsg, err := FromJsonRpcMessage(jmsg, ms.TypedId)
-			if err != nil {
+		// Notifications and initialize are handled in order, so that no
+		// request overtakes the handshake
+		if !message.IsRequest(ms) || ms.Method == message.MethodInitialize {
+			if message.IsRequest(ms) {
+				sess.pending.Add(1)
+			}
+			if err := g.handleMessage(ctx, sess, ms); err != nil {
 				return err
 			}
-			// TODO: debug log the response
-			// fmt.Printf("Sending message...: %s\n", pbmsg)
+			continue
+		}
+
+		// Other requests are handled concurrently, so that a slow handler
+		// does not hold up the rest of the session, up to the limit of the
+		// session
+		select {
+		case sess.slots <- struct{}{}:
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		}
+		sess.pending.Add(1)
+		go func() {
+			defer func() { <-sess.slots }()
+			if err := g.handleMessage(ctx, sess, ms); err != nil {
+				sess.fail(err)
+			}
+		}()
+	}
+}
+
+// notify sends the notifications of the MCP server to the client until the
+// session ends
+func (g *GrpcServer) notify(ctx context.Context, sess *grpcSession) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n := <-sess.notifications:
+			msg, err := FromJsonRpcMessage(n, nil)
+			if err != nil {
+				g.metrics.ConversionFailed(metrics.DirectionOut)
+				g.hooks.ConversionFailed(sess.info, hooks.DirectionOut, n, err)
+				continue
+			}
+			if err := g.send(sess, msg.Method, msg); err != nil {
+				sess.fail(err)
+				return
+			}
+		}
+	}
+}
+
+func (g *GrpcServer) handleMessage(ctx context.Context, sess *grpcSession, ms *pb.GenericJSONRPCMessage) error {
+	start := time.Now()
+	sess.received.Add(1)
+	g.metrics.MessageReceived(ms.Method)
+	g.transcript.Record(sess.info.ID, transcript.DirectionToServer, ms)
+	sess.admin.Received(ms)
+	g.hooks.Received(sess.info, ms)
+
+	// reply answers a request exactly once, as a timeout or the admin
+	// service can answer it while the handler is still running, and ends
+	// its record, span and latency
+	isRequest := message.IsRequest(ms)
+	var record *audit.Record
+	var span tracing.Span
+	var answered atomic.Bool
+	reply := func(resp *pb.GenericJSONRPCMessage) error {
+		if !answered.CompareAndSwap(false, true) {
+			return nil
+		}
+		if isRequest {
+			defer sess.pending.Done()
+		}
+		if ms.Method == message.MethodToolsCall {
+			g.metrics.ToolCallDuration(message.ToolName(ms), metrics.Outcome(resp), time.Since(start))
+		}
+		record.Finish(resp)
+		tracing.End(span, resp)
+		if err := g.send(sess, ms.Method, resp); err != nil {
+			return err
+		}
+		g.hooks.Responded(sess.info, ms, resp, start)
+		return nil
+	}
+	defer func() {
+		if isRequest && answered.CompareAndSwap(false, true) {
+			record.Abort()
+			tracing.End(span, nil)
+			sess.pending.Done()
+		}
+	}()
+
+	// The record starts before the limits are checked, so that the
+	// requests they reject are audited too
+	record = g.audit.Start(sess.info, ms)
 
-			if er := stream.Send(pbmsg); er != nil {
-				return er
+	if resp, err := g.limits.Request(ms); err != nil {
+		if resp == nil {
+			g.errLog.Printf("Dropping message: %v", err)
+			return nil
+		}
+		return reply(resp)
+	}
+
+	if isRequest {
+		tool := message.ToolName(ms)
+		parent, _ := tracing.Extract(ctx, ms.Params)
+		ctx, span = tracing.StartRequest(ctx, g.tracer, tracing.SpanName(ms.Method, tool), parent, tracing.RequestAttributes(sess.info.ID, tool, ms))
+
+		if claims := jwtauth.ClaimsFromContext(ctx); claims != nil {
+			if err := g.auth.Authorize(claims, ms.Method); err != nil {
+				return reply(message.NewError(ms.TypedId, jwtauth.ErrorCode, err.Error(), nil))
 			}
 		}
+
+		release, err := g.limiter.Acquire(ratelimit.Request{
+			Principal: sess.info.Principal,
+			Session:   sess.info.ID,
+			Method:    ms.Method,
+			Tool:      tool,
+		})
+		if limitErr, ok := err.(*ratelimit.Error); ok {
+			return reply(message.NewError(ms.TypedId, ratelimit.ErrorCode, limitErr.Error(), limitErr.Data()))
+		}
+		defer release()
+
+		var cancel context.CancelFunc
+		ctx, cancel = timeout.WithTimeout(ctx, g.timeouts, ms)
+		defer cancel()
+		context.AfterFunc(ctx, func() {
+			if e := timeout.Exceeded(ctx); e != nil {
+				if err := reply(message.NewError(ms.TypedId, timeout.ErrorCode, e.Error(), e.Data())); err != nil {
+					g.errLog.Printf("Failed to send timeout: %v", err)
+				}
+			}
+		})
+		sess.admin.Start(ms, func(reason string) {
+			if err := reply(admin.CancelledError(ms.TypedId, reason)); err != nil {
+				g.errLog.Printf("Failed to send cancellation: %v", err)
+			}
+			cancel()
+		})
 	}
 
+	baseMsg, err := ToJsonRpcMessage(ms)
+	if err != nil {
+		g.metrics.ConversionFailed(metrics.DirectionIn)
+		g.hooks.ConversionFailed(sess.info, hooks.DirectionIn, ms, err)
+		return err
+	}
+
+	jmsg := g.mcpserver.HandleMessage(ctx, baseMsg)
+	if e := timeout.Exceeded(ctx); e != nil {
+		// The handler gave up on its cancelled context
+		return reply(message.NewError(ms.TypedId, timeout.ErrorCode, e.Error(), e.Data()))
+	}
+	if jmsg == nil {
+		// Notifications and responses have nothing to send back
+		return nil
+	}
+
+	pbmsg, err := FromJsonRpcMessage(jmsg, ms.TypedId)
+	if err != nil {
+		g.metrics.ConversionFailed(metrics.DirectionOut)
+		g.hooks.ConversionFailed(sess.info, hooks.DirectionOut, jmsg, err)
+		return err
+	}
+	// TODO: debug log the response
+	// fmt.Printf("Sending message...: %s\n", pbmsg)
+
+	return reply(g.limits.Response(pbmsg))
+}
+
+// send sends a message on the session's stream. The method is the one of the
+// message itself, or of the request it answers.
+func (g *GrpcServer) send(sess *grpcSession, method string, msg *pb.GenericJSONRPCMessage) error {
+	g.metrics.MessageSent(method)
+	if e := msg.GetError(); e != nil {
+		g.metrics.ErrorSent(method, e.Code)
+	}
+	sess.admin.Sent(msg, method)
+	g.transcript.Record(sess.info.ID, transcript.DirectionToClient, msg)
+	if err := sess.send(msg); err != nil {
+		return err
+	}
+	sess.sent.Add(1)
+	g.hooks.Notified(sess.info, msg)
 	return nil
 }
 
@@ -147,8 +586,8 @@ func FromJsonRpcMessage(m mcp.JSONRPCMessage, id *pb.ID) (*pb.GenericJSONRPCMess
 		msg.Error = &pb.JSONRPCError{
 			Code:    int32(v.Error.Code),
 			Message: v.Error.Message,
-			// Data:    v.Error.Data,
 		}
+		codec.SetData(msg.Error, v.Error.Data)
 
 	case mcp.JSONRPCNotification:
 		msg.Jsonrpc = v.JSONRPC
@@ -196,11 +635,15 @@ func ToJsonRpcMessage(m *pb.GenericJSONRPCMessage) (json.RawMessage, error) {
 
 	case m.TypedId != nil && m.Error != nil:
 		// JSON-RPC Error
+		var data any
+		if raw := codec.ErrorData(m.Error); raw != nil {
+			data = raw
+		}
 		tmp := mcp.NewJSONRPCError(
 			mcp.NewRequestId(m.TypedId),
 			int(m.Error.Code),
 			m.Error.Message,
-			m.Error.Data,
+			data,
 		)
 		return marshalToRawMessage(tmp)
 
@@ -0,0 +1,251 @@
+package grpc
+
+import (
+	"context"
+	"log"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	mcpsrv "github.com/mark3labs/mcp-go/server"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/audit"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/hooks"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/limits"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/metrics"
+	pb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/jsonrpc"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/timeout"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestMaxConcurrentRequests(t *testing.T) {
+	var running, peak atomic.Int32
+	s := mcpsrv.NewMCPServer("test", "1.0.0")
+	s.AddTool(mcp.NewTool("slow"), func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		return mcp.NewToolResultText("done"), nil
+	})
+
+	c := dial(t, NewGrpcServer(s, WithMaxConcurrentRequests(2)))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for range 6 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			call := mcp.CallToolRequest{}
+			call.Params.Name = "slow"
+			if _, err := c.CallTool(ctx, call); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if p := peak.Load(); p < 1 || p > 2 {
+		t.Errorf("expected at most 2 requests to be handled at once, got %d", p)
+	}
+}
+
+type memorySink struct {
+	mu     sync.Mutex
+	events []audit.Event
+}
+
+func (s *memorySink) Write(e audit.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, e)
+	return nil
+}
+
+func TestAuditsRequestsOverLimits(t *testing.T) {
+	s := mcpsrv.NewMCPServer("test", "1.0.0")
+	s.AddTool(mcp.NewTool("echo"), func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("done"), nil
+	})
+	sink := &memorySink{}
+	c := dial(t, NewGrpcServer(s, WithAuditLogger(audit.New(sink)), WithMessageLimits(limits.Limits{MaxParamsBytes: 512})))
+
+	call := mcp.CallToolRequest{}
+	call.Params.Name = "echo"
+	call.Params.Arguments = map[string]any{"text": strings.Repeat("x", 1024)}
+	if _, err := c.CallTool(context.Background(), call); err == nil {
+		t.Fatal("expected the oversized call to be rejected")
+	}
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if len(sink.events) != 1 {
+		t.Fatalf("expected one audit event, got %d", len(sink.events))
+	}
+	if e := sink.events[0]; e.Status != audit.StatusError || e.ErrorCode != limits.ErrorCodeParams || e.Tool != "echo" {
+		t.Errorf("unexpected audit event: %+v", e)
+	}
+}
+
+type durationRecorder struct {
+	metrics.Recorder
+	mu       sync.Mutex
+	outcomes []string
+}
+
+func (r *durationRecorder) ToolCallDuration(tool, outcome string, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.outcomes = append(r.outcomes, tool+" "+outcome)
+}
+
+func TestToolCallDurationOutcomes(t *testing.T) {
+	s := mcpsrv.NewMCPServer("test", "1.0.0")
+	s.AddTool(mcp.NewTool("echo"), func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("done"), nil
+	})
+	s.AddTool(mcp.NewTool("hang"), func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	rec := &durationRecorder{Recorder: metrics.Discard}
+	c := dial(t, NewGrpcServer(s, WithMetrics(rec), WithTimeouts(timeout.Config{Tools: map[string]time.Duration{"hang": 10 * time.Millisecond}})))
+
+	for _, tool := range []string{"echo", "hang"} {
+		call := mcp.CallToolRequest{}
+		call.Params.Name = tool
+		_, _ = c.CallTool(context.Background(), call)
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	want := []string{"echo " + metrics.OutcomeOK, "hang " + metrics.OutcomeTimeout}
+	if !slices.Equal(rec.outcomes, want) {
+		t.Errorf("expected %v to be observed, got %v", want, rec.outcomes)
+	}
+}
+
+func TestNotificationHook(t *testing.T) {
+	s := mcpsrv.NewMCPServer("test", "1.0.0")
+	s.AddTool(mcp.NewTool("progress"), func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if err := s.SendNotificationToClient(ctx, "notifications/message", map[string]any{"level": "info", "data": "working"}); err != nil {
+			return nil, err
+		}
+		return mcp.NewToolResultText("done"), nil
+	})
+	notified := make(chan hooks.NotificationEvent, 1)
+	c := dial(t, NewGrpcServer(s, WithHooks(&hooks.Hooks{
+		OnNotification: func(e hooks.NotificationEvent) { notified <- e },
+	})))
+	received := make(chan mcp.JSONRPCNotification, 1)
+	c.OnNotification(func(n mcp.JSONRPCNotification) { received <- n })
+
+	call := mcp.CallToolRequest{}
+	call.Params.Name = "progress"
+	if _, err := c.CallTool(context.Background(), call); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case e := <-notified:
+		if e.Method != "notifications/message" || e.Session == nil {
+			t.Errorf("unexpected notification event: %+v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the notification hook to be called")
+	}
+	select {
+	case n := <-received:
+		if n.Params.AdditionalFields["data"] != "working" {
+			t.Errorf("unexpected notification: %+v", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the client to receive the notification")
+	}
+}
+
+// lines passes on what is written to it
+type lines chan string
+
+func (l lines) Write(p []byte) (int, error) {
+	l <- string(p)
+	return len(p), nil
+}
+
+func TestLogsDroppedMessages(t *testing.T) {
+	logged := make(lines, 1)
+	srv := NewGrpcServer(mcpsrv.NewMCPServer("test", "1.0.0"), WithMessageLimits(limits.Limits{MaxParamsBytes: 64}), WithErrorLogger(log.New(logged, "", 0)))
+	stream, err := pb.NewJSONRPCServiceClient(serve(t, srv)).Transport(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	params, err := structpb.NewStruct(map[string]any{"data": strings.Repeat("x", 1024)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&pb.GenericJSONRPCMessage{Jsonrpc: "2.0", Method: "notifications/message", Params: params}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case line := <-logged:
+		if !strings.HasPrefix(line, "Dropping message: ") {
+			t.Errorf("expected the dropped notification to be logged, got %q", line)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the dropped notification to be logged")
+	}
+}
+
+// dial serves srv over an in-memory connection and returns an initialized
+// client of it
+func dial(t *testing.T, srv *GrpcServer) *client.Client {
+	t.Helper()
+	conn := serve(t, srv)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c := client.NewClient(NewGrpcClient(conn))
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	init := mcp.InitializeRequest{}
+	init.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	if _, err := c.Initialize(ctx, init); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// serve serves srv over an in-memory listener, and returns a connection to it
+func serve(t *testing.T, srv *GrpcServer) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer()
+	pb.RegisterJSONRPCServiceServer(gs, srv)
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
@@ -0,0 +1,65 @@
+package grpc
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	mcpsrv "github.com/mark3labs/mcp-go/server"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/admin"
+	pb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/jsonrpc"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/session"
+)
+
+// grpcSession holds the state of a single Transport stream
+type grpcSession struct {
+	info   *session.Info
+	stream pb.JSONRPCService_TransportServer
+	admin  *admin.Session
+
+	// fail ends the session with an error from a request handler
+	fail func(error)
+	// pending counts the requests which have not been answered yet
+	pending sync.WaitGroup
+	// slots holds a token for every request being handled
+	slots chan struct{}
+
+	// received and sent count the messages of the session
+	received, sent atomic.Uint64
+
+	// sendMu serializes stream.Send, as requests are answered concurrently
+	sendMu sync.Mutex
+
+	// notifications carries the notifications of the MCP server to the
+	// client, once the session is initialized
+	notifications chan mcp.JSONRPCNotification
+	initialized   atomic.Bool
+}
+
+// notificationBuffer is the number of notifications a session holds while
+// its stream is busy, beyond which the MCP server drops them
+const notificationBuffer = 100
+
+var _ mcpsrv.ClientSession = (*grpcSession)(nil)
+
+func (s *grpcSession) Initialize() {
+	s.initialized.Store(true)
+}
+
+func (s *grpcSession) Initialized() bool {
+	return s.initialized.Load()
+}
+
+func (s *grpcSession) NotificationChannel() chan<- mcp.JSONRPCNotification {
+	return s.notifications
+}
+
+func (s *grpcSession) SessionID() string {
+	return s.info.ID
+}
+
+func (s *grpcSession) send(msg *pb.GenericJSONRPCMessage) error {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	return s.stream.Send(msg)
+}
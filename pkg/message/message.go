@@ -0,0 +1,114 @@
+// Package message provides library-neutral helpers for inspecting and
+// building GenericJSONRPCMessage frames.
+package message
+
+import (
+	"fmt"
+
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/codec"
+	pb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/jsonrpc"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const JSONRPCVersion = "2.0"
+
+// MCP methods which the transports treat specially
+const (
+	MethodInitialize    = "initialize"
+	MethodToolsCall     = "tools/call"
+	MethodResourcesRead = "resources/read"
+	MethodPromptsGet    = "prompts/get"
+	MethodCancelled     = "notifications/cancelled"
+)
+
+// JSON-RPC error codes of the responses which the transports send in place
+// of a handler's
+const (
+	// ErrorCodeTimeout answers requests which timed out
+	ErrorCodeTimeout = -32003
+	// ErrorCodeCancelled answers requests cancelled by an operator
+	ErrorCodeCancelled = -32800
+)
+
+// IsRequest reports whether the message is a JSON-RPC request
+func IsRequest(m *pb.GenericJSONRPCMessage) bool {
+	return m.GetTypedId() != nil && m.GetMethod() != ""
+}
+
+// IsNotification reports whether the message is a JSON-RPC notification
+func IsNotification(m *pb.GenericJSONRPCMessage) bool {
+	return m.GetTypedId() == nil && m.GetMethod() != ""
+}
+
+// IsResponse reports whether the message is a JSON-RPC response or error
+func IsResponse(m *pb.GenericJSONRPCMessage) bool {
+	return m.GetTypedId() != nil && m.GetMethod() == ""
+}
+
+// ToolName returns the tool name of a tools/call request, or an empty string
+// for any other message.
+func ToolName(m *pb.GenericJSONRPCMessage) string {
+	if m.GetMethod() != MethodToolsCall {
+		return ""
+	}
+	return m.GetParams().GetFields()["name"].GetStringValue()
+}
+
+// NewError builds a JSON-RPC error response for the given request ID, with
+// optional data of any JSON value
+func NewError(id *pb.ID, code int32, msg string, data any) *pb.GenericJSONRPCMessage {
+	e := &pb.JSONRPCError{Code: code, Message: msg}
+	codec.SetData(e, data)
+	return &pb.GenericJSONRPCMessage{
+		Jsonrpc: JSONRPCVersion,
+		TypedId: id,
+		Error:   e,
+	}
+}
+
+// NewCancelled builds the notification telling the peer that the request
+// with the given ID is no longer awaited
+func NewCancelled(id *pb.ID, reason string) *pb.GenericJSONRPCMessage {
+	requestID := structpb.NewNumberValue(float64(id.GetNum()))
+	if v, ok := id.GetKind().(*pb.ID_Str); ok {
+		requestID = structpb.NewStringValue(v.Str)
+	}
+	params := &structpb.Struct{Fields: map[string]*structpb.Value{"requestId": requestID}}
+	if reason != "" {
+		params.Fields["reason"] = structpb.NewStringValue(reason)
+	}
+	return &pb.GenericJSONRPCMessage{
+		Jsonrpc: JSONRPCVersion,
+		Method:  MethodCancelled,
+		Params:  params,
+	}
+}
+
+// CancelledID returns the ID of the request a notifications/cancelled
+// message refers to, or nil for any other message.
+func CancelledID(m *pb.GenericJSONRPCMessage) *pb.ID {
+	if m.GetMethod() != MethodCancelled {
+		return nil
+	}
+	switch v := m.GetParams().GetFields()["requestId"].GetKind().(type) {
+	case *structpb.Value_NumberValue:
+		return &pb.ID{Kind: &pb.ID_Num{Num: int64(v.NumberValue)}}
+	case *structpb.Value_StringValue:
+		return &pb.ID{Kind: &pb.ID_Str{Str: v.StringValue}}
+	default:
+		return nil
+	}
+}
+
+// IDKey returns a key identifying a request ID, which tells numeric and
+// string IDs apart.
+func IDKey(id *pb.ID) string {
+	switch v := id.GetKind().(type) {
+	case *pb.ID_Num:
+		return fmt.Sprint(v.Num)
+	case *pb.ID_Str:
+		return fmt.Sprintf("%q", v.Str)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,176 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/metoro-io/mcp-golang/transport"
+	pb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/jsonrpc"
+	"google.golang.org/grpc"
+)
+
+// GrpcClientTransport implements client-side transport for grpc
+// communication, over a JSONRPCService Transport stream, for use with
+// mcp_golang.NewClient:
+//
+//	conn, err := grpc.NewClient("localhost:50051", grpc.WithTransportCredentials(insecure.NewCredentials()))
+//	client := mcp_golang.NewClient(grpctransport.NewGrpcClientTransport(conn))
+//	_, err = client.Initialize(ctx)
+type GrpcClientTransport struct {
+	mu        sync.Mutex
+	onClose   func()
+	onError   func(error)
+	onMessage func(ctx context.Context, message *transport.BaseJsonRpcMessage)
+
+	client   pb.JSONRPCServiceClient
+	callOpts []grpc.CallOption
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	stream pb.JSONRPCService_TransportClient
+	// sendMu serializes stream.Send, as messages are sent concurrently
+	sendMu    sync.Mutex
+	closeOnce sync.Once
+}
+
+var _ transport.Transport = (*GrpcClientTransport)(nil)
+
+type GrpcClientTransportOption func(*GrpcClientTransport)
+
+// WithCallOpts sets the options of the Transport call, e.g. per-RPC
+// credentials or grpc.WaitForReady
+func WithCallOpts(opts ...grpc.CallOption) GrpcClientTransportOption {
+	return func(t *GrpcClientTransport) {
+		t.callOpts = opts
+	}
+}
+
+// NewGrpcClientTransport creates a new GRPC client Transport which opens its
+// stream on conn. The connection is owned by the caller, and is not closed
+// by Close.
+func NewGrpcClientTransport(conn grpc.ClientConnInterface, opts ...GrpcClientTransportOption) *GrpcClientTransport {
+	t := &GrpcClientTransport{client: pb.NewJSONRPCServiceClient(conn)}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Start opens the stream and dispatches the messages received on it to the
+// message handler. The stream stays open until Close rather than until ctx
+// is done, but keeps the values of ctx such as its outgoing metadata.
+func (t *GrpcClientTransport) Start(ctx context.Context) error {
+	if t.stream != nil {
+		return errors.New("transport already started")
+	}
+	t.ctx, t.cancel = context.WithCancel(context.WithoutCancel(ctx))
+	stream, err := t.client.Transport(t.ctx, t.callOpts...)
+	if err != nil {
+		t.cancel()
+		return fmt.Errorf("failed to open stream: %w", err)
+	}
+	t.stream = stream
+	go t.receive()
+	return nil
+}
+
+// Send sends a JSON-RPC message
+func (t *GrpcClientTransport) Send(ctx context.Context, message *transport.BaseJsonRpcMessage) error {
+	if t.stream == nil {
+		return errors.New("transport not started")
+	}
+	msg, err := ToGenericRpcMessage(message)
+	if err != nil {
+		return err
+	}
+	t.sendMu.Lock()
+	defer t.sendMu.Unlock()
+	return t.stream.Send(msg)
+}
+
+// Close ends the stream and calls the close handler
+func (t *GrpcClientTransport) Close() error {
+	if t.stream == nil {
+		return nil
+	}
+	t.sendMu.Lock()
+	_ = t.stream.CloseSend()
+	t.sendMu.Unlock()
+	t.finish(nil)
+	return nil
+}
+
+func (t *GrpcClientTransport) SetCloseHandler(handler func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onClose = handler
+}
+
+func (t *GrpcClientTransport) SetErrorHandler(handler func(error)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onError = handler
+}
+
+func (t *GrpcClientTransport) SetMessageHandler(handler func(ctx context.Context, message *transport.BaseJsonRpcMessage)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onMessage = handler
+}
+
+func (t *GrpcClientTransport) receive() {
+	for {
+		ms, err := t.stream.Recv()
+		if err == io.EOF {
+			t.finish(nil)
+			return
+		}
+		if err != nil {
+			t.finish(err)
+			return
+		}
+
+		// A message which cannot be converted is reported, and the stream
+		// stays up
+		msg, err := ToBaseJsonRpcMessage(ms)
+		if err != nil {
+			t.handleError(fmt.Errorf("failed to convert message: %w", err))
+			continue
+		}
+		t.mu.Lock()
+		onMessage := t.onMessage
+		t.mu.Unlock()
+		if onMessage != nil {
+			onMessage(t.ctx, msg)
+		}
+	}
+}
+
+// finish ends the stream once, reporting err unless it was closed by Close
+func (t *GrpcClientTransport) finish(err error) {
+	t.closeOnce.Do(func() {
+		if err != nil {
+			t.handleError(err)
+		}
+		t.cancel()
+
+		t.mu.Lock()
+		onClose := t.onClose
+		t.mu.Unlock()
+		if onClose != nil {
+			onClose()
+		}
+	})
+}
+
+func (t *GrpcClientTransport) handleError(err error) {
+	t.mu.Lock()
+	onError := t.onError
+	t.mu.Unlock()
+	if onError != nil {
+		onError(err)
+	}
+}
@@ -0,0 +1,80 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	mcp_golang "github.com/metoro-io/mcp-golang"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+type helloArgs struct {
+	Name string `json:"name" jsonschema:"required"`
+}
+
+func TestGrpcClientTransport(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := lis.Addr().(*net.TCPAddr).Port
+	lis.Close()
+
+	server := mcp_golang.NewServer(NewGrpcServerTransport(WithHost("127.0.0.1"), WithPort(port)))
+	err = server.RegisterTool("hello", "Says hello", func(args helloArgs) (*mcp_golang.ToolResponse, error) {
+		return mcp_golang.NewToolResponse(mcp_golang.NewTextContent("Hello, " + args.Name + "!")), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve()
+
+	conn, err := grpc.NewClient(fmt.Sprintf("127.0.0.1:%d", port), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	tr := NewGrpcClientTransport(conn, WithCallOpts(grpc.WaitForReady(true)))
+	closed := make(chan struct{})
+	client := mcp_golang.NewClient(tr)
+	if _, err := client.Initialize(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// The protocol sets its own handler on Initialize
+	tr.SetCloseHandler(func() { close(closed) })
+
+	tools, err := client.ListTools(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tools.Tools) != 1 || tools.Tools[0].Name != "hello" {
+		t.Errorf("expected the tool 'hello', got %+v", tools.Tools)
+	}
+
+	result, err := client.CallTool(ctx, "hello", helloArgs{Name: "Ann"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Content) != 1 || result.Content[0].TextContent == nil || result.Content[0].TextContent.Text != "Hello, Ann!" {
+		t.Errorf("expected 'Hello, Ann!', got %+v", result.Content)
+	}
+
+	if err := tr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-closed:
+	case <-ctx.Done():
+		t.Fatal("close handler not called")
+	}
+	if err := tr.Close(); err != nil {
+		t.Errorf("expected a second close to succeed, got %v", err)
+	}
+}
@@ -3,32 +3,75 @@ package grpc
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/metoro-io/mcp-golang/transport"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/admin"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/audit"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/codec"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/hooks"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/jwtauth"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/limits"
+	mcpmsg "github.com/rustycl0ck/mcp-grpc-transport/pkg/message"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/metrics"
+	adminpb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/admin"
 	pb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/jsonrpc"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/ratelimit"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/session"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/timeout"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/tlsreload"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/tracing"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/transcript"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/structpb"
 )
 
 // GrpcServerTransport implements server-side transport for grpc communication
 type GrpcServerTransport struct {
 	pb.UnimplementedJSONRPCServiceServer
-	mu        sync.Mutex
-	onClose   func()
-	onError   func(error)
-	onMessage func(ctx context.Context, message *transport.BaseJsonRpcMessage)
-	host      string
-	port      int
-	grpcOpts  []grpc.ServerOption
+	mu         sync.Mutex
+	onClose    func()
+	onError    func(error)
+	onMessage  func(ctx context.Context, message *transport.BaseJsonRpcMessage)
+	host       string
+	port       int
+	grpcOpts   []grpc.ServerOption
+	limiter    *ratelimit.Limiter
+	principal  session.PrincipalFunc
+	tlsFiles   *tlsFiles
+	audit      *audit.Logger
+	limits     *limits.Limits
+	auth       *jwtauth.Authenticator
+	metrics    metrics.Recorder
+	tracer     tracing.Tracer
+	admin      *admin.Admin
+	transcript *transcript.Recorder
+	timeouts   *timeout.Config
+	hooks      *hooks.Hooks
+	sessions   sync.Map // session ID -> *grpcSession
+	// requests holds the in-flight requests of every session by the ID
+	// which the protocol sees in place of the client's
+	requests sync.Map // transport.RequestId -> *inflightRequest
+	lastID   atomic.Int64
 }
 
 type GrpcServerTransportOption func(*GrpcServerTransport)
 
+type tlsFiles struct {
+	cert, key, clientCA string
+}
+
 func WithHost(host string) GrpcServerTransportOption {
 	return func(s *GrpcServerTransport) {
 		s.host = host
@@ -47,10 +90,117 @@ func WithGrpcOpts(opts ...grpc.ServerOption) GrpcServerTransportOption {
 	}
 }
 
+// WithTLS serves TLS using the certificate and key files. If clientCAFile is
+// set, clients must present a certificate signed by one of its CAs. The files
+// are watched and rotated material is picked up without a restart.
+func WithTLS(certFile, keyFile, clientCAFile string) GrpcServerTransportOption {
+	return func(s *GrpcServerTransport) {
+		s.tlsFiles = &tlsFiles{cert: certFile, key: keyFile, clientCA: clientCAFile}
+	}
+}
+
+// WithRateLimiter applies the limiter to every request received on the server.
+// Over-limit requests are answered with a JSON-RPC error carrying a retry hint.
+func WithRateLimiter(l *ratelimit.Limiter) GrpcServerTransportOption {
+	return func(s *GrpcServerTransport) {
+		s.limiter = l
+	}
+}
+
+// WithAuditLogger records an audit event for every tools/call, resources/read
+// and prompts/get request handled by the server.
+func WithAuditLogger(l *audit.Logger) GrpcServerTransportOption {
+	return func(s *GrpcServerTransport) {
+		s.audit = l
+	}
+}
+
+// WithMessageLimits bounds the size and complexity of incoming params and
+// outgoing results. Violating requests are answered with a JSON-RPC error
+// and the stream stays up.
+func WithMessageLimits(l limits.Limits) GrpcServerTransportOption {
+	return func(s *GrpcServerTransport) {
+		s.limits = &l
+	}
+}
+
+// WithAuthenticator requires every stream to present a valid JWT in its
+// "authorization" metadata. The token's subject becomes the principal, its
+// scopes are checked for every request, and its claims are available to
+// handlers through jwtauth.ClaimsFromContext.
+func WithAuthenticator(a *jwtauth.Authenticator) GrpcServerTransportOption {
+	return func(s *GrpcServerTransport) {
+		s.auth = a
+	}
+}
+
+// WithMetrics records MCP-level metrics of the sessions and messages handled
+// by the server, e.g. with metrics.NewPrometheus.
+func WithMetrics(r metrics.Recorder) GrpcServerTransportOption {
+	return func(s *GrpcServerTransport) {
+		s.metrics = r
+	}
+}
+
+// WithTracer starts a span for every request handled by the server. The W3C
+// trace context of the request's "_meta", or else of the stream metadata,
+// becomes its parent. Without a tracer the received trace context is still
+// passed to handlers through tracing.FromContext.
+func WithTracer(t tracing.Tracer) GrpcServerTransportOption {
+	return func(s *GrpcServerTransport) {
+		s.tracer = t
+	}
+}
+
+// WithAdmin serves the MCPAdmin service next to the JSON-RPC service, to
+// inspect the live sessions and cancel requests or terminate sessions. Its
+// calls are authenticated like streams with WithAuthenticator, and denied
+// unless the Admin has an authorizer.
+func WithAdmin(a *admin.Admin) GrpcServerTransportOption {
+	return func(s *GrpcServerTransport) {
+		s.admin = a
+	}
+}
+
+// WithTranscript records every message of every session, e.g. to capture
+// a bug report which can be replayed with the client's replay command.
+func WithTranscript(r *transcript.Recorder) GrpcServerTransportOption {
+	return func(s *GrpcServerTransport) {
+		s.transcript = r
+	}
+}
+
+// WithTimeouts bounds how long request handlers may run. A request which
+// exceeds its budget is answered with a JSON-RPC timeout error and its
+// handler's context is cancelled. Timeouts from a request's "_meta" and the
+// stream's gRPC deadline are honored even without this option.
+func WithTimeouts(c timeout.Config) GrpcServerTransportOption {
+	return func(s *GrpcServerTransport) {
+		s.timeouts = &c
+	}
+}
+
+// WithHooks calls the hooks on the lifecycle events of every session, e.g.
+// to attach billing or analytics to the server.
+func WithHooks(h *hooks.Hooks) GrpcServerTransportOption {
+	return func(s *GrpcServerTransport) {
+		s.hooks = h
+	}
+}
+
+// WithPrincipalFunc sets how the authenticated principal of a stream is
+// determined. Defaults to session.DefaultPrincipal.
+func WithPrincipalFunc(fn session.PrincipalFunc) GrpcServerTransportOption {
+	return func(s *GrpcServerTransport) {
+		s.principal = fn
+	}
+}
+
 // NewGrpcServerTransport creates a new GRPC ServerTransport
 func NewGrpcServerTransport(opts ...GrpcServerTransportOption) *GrpcServerTransport {
 	srv := &GrpcServerTransport{
-		port: 50051,
+		port:    50051,
+		metrics: metrics.Discard,
 	}
 	for _, opt := range opts {
 		opt(srv)
@@ -61,14 +211,43 @@ func NewGrpcServerTransport(opts ...GrpcServerTransportOption) *GrpcServerTransp
 type ctxKey string
 
 func (t *GrpcServerTransport) Start(ctx context.Context) error {
+	grpcOpts := t.grpcOpts
+	if t.tlsFiles != nil {
+		var opts []tlsreload.Option
+		if t.tlsFiles.clientCA != "" {
+			opts = append(opts, tlsreload.WithClientCA(t.tlsFiles.clientCA))
+		}
+		reloader, err := tlsreload.New(t.tlsFiles.cert, t.tlsFiles.key, opts...)
+		if err != nil {
+			return err
+		}
+		go reloader.Watch(ctx)
+		grpcOpts = append(slices.Clone(grpcOpts), grpc.Creds(reloader.Credentials()))
+	}
+
 	lis, err := net.Listen("tcp", fmt.Sprintf("%s:%d", t.host, t.port))
 	if err != nil {
 		return err
 	}
-	grpcServer := grpc.NewServer(t.grpcOpts...)
+	grpcServer := grpc.NewServer(grpcOpts...)
 	reflection.Register(grpcServer)
 
 	pb.RegisterJSONRPCServiceServer(grpcServer, t)
+	if t.admin != nil {
+		// The admin service shares the port, so it is held to the same
+		// authentication as the streams
+		var adminServer adminpb.MCPAdminServer = t.admin
+		if t.auth != nil {
+			adminServer = t.admin.Authenticated(func(ctx context.Context) (context.Context, error) {
+				claims, err := t.auth.Authenticate(ctx)
+				if err != nil {
+					return nil, err
+				}
+				return jwtauth.WithClaims(ctx, claims), nil
+			})
+		}
+		adminpb.RegisterMCPAdminServer(grpcServer, adminServer)
+	}
 
 	return grpcServer.Serve(lis)
 }
@@ -81,19 +260,117 @@ func (t *GrpcServerTransport) Close() error {
 func (t *GrpcServerTransport) Send(ctx context.Context, message *transport.BaseJsonRpcMessage) error {
 	// TODO: debug log the response
 
-	stream := ctx.Value(ctxKey("stream")).(pb.JSONRPCService_TransportServer)
-	if stream == nil {
-		return fmt.Errorf("could not find the stream for sending response; ctx: %v", ctx)
+	id, isReply := replyID(message)
+	var req *inflightRequest
+	sess, ok := ctx.Value(ctxKey("session")).(*grpcSession)
+	switch {
+	case isReply:
+		// The protocol sends error responses with a background context,
+		// so replies find their session through the request they answer
+		v, ok := t.requests.LoadAndDelete(id)
+		if !ok {
+			return fmt.Errorf("could not find the stream for sending response; id: %d", id)
+		}
+		req = v.(*inflightRequest)
+		sess = req.sess
+	case !ok:
+		// Notifications of the server, e.g. of a changed tool list, are
+		// sent with a background context and concern every session
+		return t.broadcast(message)
+	}
+
+	msg, err := ToGenericRpcMessage(message)
+	if err != nil {
+		t.metrics.ConversionFailed(metrics.DirectionOut)
+		t.hooks.ConversionFailed(sess.info, hooks.DirectionOut, message, err)
+		return fmt.Errorf("failed to convert BaseJsonRpcMessage to GenericRpcMessage; msg: %v; err: %v", message, err)
 	}
+	if req != nil {
+		// The client gets the reply under its own request ID
+		msg.TypedId = req.request.TypedId
+	}
+	msg = t.limits.Response(msg)
+
+	if req != nil {
+		if req := sess.finish(mcpmsg.IDKey(msg.TypedId)); req != nil {
+			if req.cancelled {
+				// Already answered by a timeout or the admin service
+				return nil
+			}
+			if e := timeout.Exceeded(req.ctx); e != nil {
+				// The handler gave up on its cancelled context
+				msg = mcpmsg.NewError(msg.TypedId, timeout.ErrorCode, e.Error(), e.Data())
+			}
+			req.cancel()
+			req.audit.Finish(msg)
+			tracing.End(req.span, msg)
+			req.release()
+			return t.respond(sess, req.request, req.start, msg)
+		}
+	}
+	return t.send(sess, msg.Method, msg)
+}
 
+// broadcast sends a notification of the server to every session
+func (t *GrpcServerTransport) broadcast(message *transport.BaseJsonRpcMessage) error {
 	msg, err := ToGenericRpcMessage(message)
 	if err != nil {
+		t.metrics.ConversionFailed(metrics.DirectionOut)
+		t.hooks.ConversionFailed(nil, hooks.DirectionOut, message, err)
 		return fmt.Errorf("failed to convert BaseJsonRpcMessage to GenericRpcMessage; msg: %v; err: %v", message, err)
 	}
+	var errs []error
+	t.sessions.Range(func(_, v any) bool {
+		if err := t.send(v.(*grpcSession), msg.Method, msg); err != nil {
+			errs = append(errs, err)
+		}
+		return true
+	})
+	return errors.Join(errs...)
+}
+
+// cancel answers an in-flight request with an error and cancels its handler,
+// once the admin service cancelled it or its timeout was exceeded.
+// The handler's own response is dropped once it arrives.
+func (t *GrpcServerTransport) cancel(sess *grpcSession, key string, resp *pb.GenericJSONRPCMessage) error {
+	req := sess.cancel(key)
+	if req == nil {
+		return nil
+	}
+	req.cancel()
+	req.audit.Finish(resp)
+	tracing.End(req.span, resp)
+	req.release()
+	return t.respond(sess, req.request, req.start, resp)
+}
 
-	if err := stream.Send(msg); err != nil {
+// respond sends the response to a request, records its latency and reports it
+// to the hooks
+func (t *GrpcServerTransport) respond(sess *grpcSession, req *pb.GenericJSONRPCMessage, start time.Time, resp *pb.GenericJSONRPCMessage) error {
+	if req.Method == mcpmsg.MethodToolsCall {
+		t.metrics.ToolCallDuration(mcpmsg.ToolName(req), metrics.Outcome(resp), time.Since(start))
+	}
+	if err := t.send(sess, req.Method, resp); err != nil {
 		return err
 	}
+	t.hooks.Responded(sess.info, req, resp, start)
+	return nil
+}
+
+// send sends a message on the session's stream. The method is the one of the
+// message itself, or of the request it answers.
+func (t *GrpcServerTransport) send(sess *grpcSession, method string, msg *pb.GenericJSONRPCMessage) error {
+	t.metrics.MessageSent(method)
+	if e := msg.GetError(); e != nil {
+		t.metrics.ErrorSent(method, e.Code)
+	}
+	sess.admin.Sent(msg, method)
+	t.transcript.Record(sess.info.ID, transcript.DirectionToClient, msg)
+	if err := sess.send(msg); err != nil {
+		return err
+	}
+	sess.sent.Add(1)
+	t.hooks.Notified(sess.info, msg)
 	return nil
 }
 
@@ -111,6 +388,15 @@ func (t *GrpcServerTransport) SetErrorHandler(handler func(error)) {
 	t.onError = handler
 }
 
+func (t *GrpcServerTransport) handleError(err error) {
+	t.mu.Lock()
+	onError := t.onError
+	t.mu.Unlock()
+	if onError != nil {
+		onError(err)
+	}
+}
+
 // SetMessageHandler sets the handler for incoming messages
 func (t *GrpcServerTransport) SetMessageHandler(handler func(ctx context.Context, message *transport.BaseJsonRpcMessage)) {
 	t.mu.Lock()
@@ -118,15 +404,67 @@ func (t *GrpcServerTransport) SetMessageHandler(handler func(ctx context.Context
 	t.onMessage = handler
 }
 
-func (t *GrpcServerTransport) Transport(stream pb.JSONRPCService_TransportServer) error {
+func (t *GrpcServerTransport) Transport(stream pb.JSONRPCService_TransportServer) (err error) {
 	fmt.Printf("transport started...\n")
 
-	ctx := context.WithValue(context.Background(), ctxKey("stream"), stream)
+	ctx := stream.Context()
+	if t.auth != nil {
+		claims, err := t.auth.Authenticate(ctx)
+		if err != nil {
+			return err
+		}
+		ctx = jwtauth.WithClaims(ctx, claims)
+		ctx = session.WithPrincipal(ctx, claims.Subject)
+	}
 
+	sess := newGrpcSession(session.New(ctx, t.principal), stream)
+	t.metrics.SessionOpened()
+	t.hooks.StreamOpened(sess.info)
+	defer func() {
+		t.sessions.Delete(sess.info.ID)
+		for _, req := range sess.close() {
+			t.requests.Delete(req.id)
+		}
+		t.metrics.SessionClosed()
+		t.limiter.CloseSession(sess.info.ID)
+		t.hooks.StreamClosed(sess.info, err, sess.received.Load(), sess.sent.Load())
+	}()
+
+	ctx, terminate := context.WithCancelCause(ctx)
+	defer terminate(nil)
+
+	sess.admin = t.admin.Open(sess.info, func(reason string) {
+		terminate(status.Error(codes.Aborted, reason))
+	})
+	defer sess.admin.Close()
+	// Publish the session only once notifications and replies can use it.
+	t.sessions.Store(sess.info.ID, sess)
+
+	ctx = session.WithInfo(ctx, sess.info)
+	ctx = context.WithValue(ctx, ctxKey("session"), sess)
+
+	// Receive in the background, so that a terminated session ends its
+	// stream even while blocked in Recv
+	errc := make(chan error, 1)
+	go func() {
+		errc <- t.serve(ctx, sess)
+	}()
+	select {
+	case err = <-errc:
+		return err
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+}
+
+func (t *GrpcServerTransport) serve(ctx context.Context, sess *grpcSession) error {
 	for {
-		ms, err := stream.Recv()
+		ms, err := sess.stream.Recv()
 		if err == io.EOF {
 			fmt.Println("Stream closed by client")
+			// The client may half-close the stream and still wait for
+			// the responses to its outstanding requests
+			sess.pending.Wait()
 			return nil
 		}
 		if err != nil {
@@ -134,17 +472,138 @@ func (t *GrpcServerTransport) Transport(stream pb.JSONRPCService_TransportServer
 		}
 		fmt.Println("Received message...")
 
-		if baseMsg, err := ToBaseJsonRpcMessage(ms); err != nil {
+		if err := t.handleMessage(ctx, sess, ms); err != nil {
 			return err
-		} else {
-			// TODO: debug log the recevied request
-			t.onMessage(ctx, baseMsg)
 		}
 	}
+}
+
+func (t *GrpcServerTransport) handleMessage(ctx context.Context, sess *grpcSession, ms *pb.GenericJSONRPCMessage) error {
+	start := time.Now()
+	sess.received.Add(1)
+	t.metrics.MessageReceived(ms.Method)
+	t.transcript.Record(sess.info.ID, transcript.DirectionToServer, ms)
+	sess.admin.Received(ms)
+	t.hooks.Received(sess.info, ms)
+
+	// The record starts before the limits are checked, so that the
+	// requests they reject are audited too
+	record := t.audit.Start(sess.info, ms)
+
+	if resp, err := t.limits.Request(ms); err != nil {
+		if resp == nil {
+			t.handleError(fmt.Errorf("dropping message: %w", err))
+			return nil
+		}
+		record.Finish(resp)
+		return t.respond(sess, ms, start, resp)
+	}
+
+	if mcpmsg.IsRequest(ms) {
+		tool := mcpmsg.ToolName(ms)
+		parent, _ := tracing.Extract(ctx, ms.Params)
+		var span tracing.Span
+		ctx, span = tracing.StartRequest(ctx, t.tracer, tracing.SpanName(ms.Method, tool), parent, tracing.RequestAttributes(sess.info.ID, tool, ms))
+
+		if claims := jwtauth.ClaimsFromContext(ctx); claims != nil {
+			if err := t.auth.Authorize(claims, ms.Method); err != nil {
+				resp := mcpmsg.NewError(ms.TypedId, jwtauth.ErrorCode, err.Error(), nil)
+				record.Finish(resp)
+				tracing.End(span, resp)
+				return t.respond(sess, ms, start, resp)
+			}
+		}
+
+		release, err := t.limiter.Acquire(ratelimit.Request{
+			Principal: sess.info.Principal,
+			Session:   sess.info.ID,
+			Method:    ms.Method,
+			Tool:      tool,
+		})
+		if limitErr, ok := err.(*ratelimit.Error); ok {
+			resp := mcpmsg.NewError(ms.TypedId, ratelimit.ErrorCode, limitErr.Error(), limitErr.Data())
+			record.Finish(resp)
+			tracing.End(span, resp)
+			return t.respond(sess, ms, start, resp)
+		}
+		key := mcpmsg.IDKey(ms.TypedId)
+		req := &inflightRequest{
+			sess:    sess,
+			request: ms,
+			id:      transport.RequestId(t.lastID.Add(1)),
+			start:   start,
+			release: release,
+			audit:   record,
+			span:    span,
+		}
+		ctx, req.cancel = timeout.WithTimeout(ctx, t.timeouts, ms)
+		req.ctx = ctx
+		if !sess.start(key, req) {
+			req.cancel()
+			release()
+			resp := mcpmsg.NewError(ms.TypedId, codec.CodeInvalidRequest, fmt.Sprintf("request ID %s is already in use by an in-flight request", key), nil)
+			record.Finish(resp)
+			tracing.End(span, resp)
+			return t.respond(sess, ms, start, resp)
+		}
+		t.requests.Store(req.id, req)
+		sess.admin.Start(ms, func(reason string) {
+			if err := t.cancel(sess, key, admin.CancelledError(ms.TypedId, reason)); err != nil {
+				t.handleError(fmt.Errorf("failed to send cancellation: %w", err))
+			}
+		})
+		context.AfterFunc(ctx, func() {
+			if e := timeout.Exceeded(ctx); e != nil {
+				if err := t.cancel(sess, key, mcpmsg.NewError(ms.TypedId, timeout.ErrorCode, e.Error(), e.Data())); err != nil {
+					t.handleError(fmt.Errorf("failed to send timeout: %w", err))
+				}
+			}
+		})
+		ms = withID(ms, req.id)
+	} else if id := mcpmsg.CancelledID(ms); id != nil {
+		// The client cancels a request by its own ID, which the protocol
+		// does not know
+		req := sess.lookup(mcpmsg.IDKey(id))
+		if req == nil {
+			return nil
+		}
+		ms = proto.Clone(ms).(*pb.GenericJSONRPCMessage)
+		ms.Params.Fields["requestId"] = structpb.NewNumberValue(float64(req.id))
+	}
 
+	baseMsg, err := ToBaseJsonRpcMessage(ms)
+	if err != nil {
+		t.metrics.ConversionFailed(metrics.DirectionIn)
+		t.hooks.ConversionFailed(sess.info, hooks.DirectionIn, ms, err)
+		return err
+	}
+	// TODO: debug log the recevied request
+	t.onMessage(ctx, baseMsg)
 	return nil
 }
 
+// withID returns a copy of the request with the ID the protocol sees for it
+func withID(m *pb.GenericJSONRPCMessage, id transport.RequestId) *pb.GenericJSONRPCMessage {
+	return &pb.GenericJSONRPCMessage{
+		Jsonrpc: m.Jsonrpc,
+		TypedId: &pb.ID{Kind: &pb.ID_Num{Num: int64(id)}},
+		Method:  m.Method,
+		Params:  m.Params,
+	}
+}
+
+// replyID returns the request ID a response or error message answers
+func replyID(m *transport.BaseJsonRpcMessage) (transport.RequestId, bool) {
+	switch m.Type {
+	case transport.BaseMessageTypeJSONRPCResponseType:
+		return m.JsonRpcResponse.Id, true
+	case transport.BaseMessageTypeJSONRPCErrorType:
+		return m.JsonRpcError.Id, true
+	default:
+		return 0, false
+	}
+}
+
 func ToBaseJsonRpcMessage(m *pb.GenericJSONRPCMessage) (*transport.BaseJsonRpcMessage, error) {
 	msg := &transport.BaseJsonRpcMessage{}
 
@@ -166,7 +625,7 @@ func ToBaseJsonRpcMessage(m *pb.GenericJSONRPCMessage) (*transport.BaseJsonRpcMe
 
 	switch msg.Type {
 	case transport.BaseMessageTypeJSONRPCRequestType:
-		params, err := m.Params.MarshalJSON()
+		params, err := codec.EncodeStruct(m.Params)
 		if err != nil {
 			return nil, err
 		}
@@ -178,7 +637,7 @@ func ToBaseJsonRpcMessage(m *pb.GenericJSONRPCMessage) (*transport.BaseJsonRpcMe
 			Params:  params,
 		}
 	case transport.BaseMessageTypeJSONRPCNotificationType:
-		params, err := m.Params.MarshalJSON()
+		params, err := codec.EncodeStruct(m.Params)
 		if err != nil {
 			return nil, err
 		}
@@ -189,7 +648,7 @@ func ToBaseJsonRpcMessage(m *pb.GenericJSONRPCMessage) (*transport.BaseJsonRpcMe
 			Params:  params,
 		}
 	case transport.BaseMessageTypeJSONRPCResponseType:
-		result, err := m.Result.MarshalJSON()
+		result, err := codec.EncodeStruct(m.Result)
 		if err != nil {
 			return nil, err
 		}
@@ -199,12 +658,16 @@ func ToBaseJsonRpcMessage(m *pb.GenericJSONRPCMessage) (*transport.BaseJsonRpcMe
 			Result:  result,
 		}
 	case transport.BaseMessageTypeJSONRPCErrorType:
+		var data any
+		if raw := codec.ErrorData(m.Error); raw != nil {
+			data = raw
+		}
 		msg.JsonRpcError = &transport.BaseJSONRPCError{
 			Jsonrpc: m.Jsonrpc,
 			Id:      id,
 			Error: transport.BaseJSONRPCErrorInner{
 				Code:    int(m.Error.Code),
-				Data:    m.Error.Data,
+				Data:    data,
 				Message: m.Error.Message,
 			},
 		}
@@ -266,10 +729,10 @@ func ToGenericRpcMessage(m *transport.BaseJsonRpcMessage) (*pb.GenericJSONRPCMes
 		msg.Jsonrpc = m.JsonRpcError.Jsonrpc
 		msg.TypedId = &pb.ID{Kind: &pb.ID_Num{Num: int64(m.JsonRpcError.Id)}}
 		msg.Error = &pb.JSONRPCError{
-			// Data:    string(m.JsonRpcError.Error.Data), // TODO: convert the data interface to string
 			Code:    int32(m.JsonRpcError.Error.Code),
 			Message: m.JsonRpcError.Error.Message,
 		}
+		codec.SetData(msg.Error, m.JsonRpcError.Error.Data)
 	default:
 		return nil, fmt.Errorf("unsupported type for BaseJsonRpcMessage")
 	}
@@ -2,11 +2,23 @@ package grpc
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"net"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/metoro-io/mcp-golang/transport"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/hooks"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/limits"
 	pb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/jsonrpc"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/session"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 func TestNewGrpcServerTransport_Defaults(t *testing.T) {
@@ -50,6 +62,30 @@ func TestSetErrorHandler(t *testing.T) {
 	}
 }
 
+func TestReportsDroppedMessages(t *testing.T) {
+	tr := NewGrpcServerTransport(WithMessageLimits(limits.Limits{MaxParamsBytes: 64}))
+	errs := make(chan error, 1)
+	tr.SetErrorHandler(func(err error) { errs <- err })
+	stream := open(t, tr, serve(t, tr))
+
+	params, err := structpb.NewStruct(map[string]any{"data": strings.Repeat("x", 1024)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&pb.GenericJSONRPCMessage{Jsonrpc: "2.0", Method: "notifications/message", Params: params}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-errs:
+		if !strings.HasPrefix(err.Error(), "dropping message: ") {
+			t.Errorf("expected the dropped notification to be reported, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the dropped notification to be reported")
+	}
+}
+
 func TestSetMessageHandler(t *testing.T) {
 	srv := NewGrpcServerTransport()
 	called := false
@@ -78,3 +114,184 @@ func TestToBaseJsonRpcMessage_UnknownType(t *testing.T) {
 		t.Error("expected error for unknown message type")
 	}
 }
+
+func TestSessionStart_DuplicateID(t *testing.T) {
+	sess := newGrpcSession(&session.Info{ID: "s1"}, nil)
+	first := &inflightRequest{request: &pb.GenericJSONRPCMessage{Method: "tools/call"}}
+	if !sess.start("1", first) {
+		t.Fatal("expected the first request to start")
+	}
+	if sess.start("1", &inflightRequest{request: &pb.GenericJSONRPCMessage{Method: "ping"}}) {
+		t.Error("expected a request reusing an in-flight ID to be rejected")
+	}
+	if req := sess.finish("1"); req != first {
+		t.Errorf("expected the first request to stay in-flight, got %+v", req)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		sess.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("expected no request to be left pending")
+	}
+}
+
+func TestNotificationHook(t *testing.T) {
+	notified := make(chan hooks.NotificationEvent, 1)
+	tr := NewGrpcServerTransport(WithHooks(&hooks.Hooks{
+		OnNotification: func(e hooks.NotificationEvent) { notified <- e },
+	}))
+	stream := open(t, tr, serve(t, tr))
+
+	// The protocol sends the notifications of the server, e.g. of a
+	// changed tool list, with a background context
+	n := transport.NewBaseMessageNotification(&transport.BaseJSONRPCNotification{
+		Jsonrpc: "2.0",
+		Method:  "notifications/tools/list_changed",
+		Params:  json.RawMessage(`{}`),
+	})
+	if err := tr.Send(context.Background(), n); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Method != "notifications/tools/list_changed" {
+		t.Errorf("expected the notification, got %v", msg)
+	}
+	select {
+	case e := <-notified:
+		if e.Method != "notifications/tools/list_changed" || e.Session == nil {
+			t.Errorf("unexpected notification event: %+v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the notification hook to be called")
+	}
+}
+
+func TestRepliesReachTheirSession(t *testing.T) {
+	requests := make(chan *transport.BaseJSONRPCRequest, 3)
+	tr := NewGrpcServerTransport()
+	tr.SetMessageHandler(func(_ context.Context, m *transport.BaseJsonRpcMessage) {
+		if m.Type == transport.BaseMessageTypeJSONRPCRequestType {
+			requests <- m.JsonRpcRequest
+		}
+	})
+	conn := serve(t, tr)
+	a, b := open(t, tr, conn), open(t, tr, conn)
+
+	// Every client numbers its requests from 1, and a string ID is
+	// distinct from the number it spells
+	num := &pb.ID{Kind: &pb.ID_Num{Num: 1}}
+	str := &pb.ID{Kind: &pb.ID_Str{Str: "1"}}
+	for _, r := range []struct {
+		stream pb.JSONRPCService_TransportClient
+		id     *pb.ID
+		label  string
+	}{{a, num, "a"}, {b, num, "b"}, {b, str, "b-str"}} {
+		params, _ := structpb.NewStruct(map[string]any{"label": r.label})
+		if err := r.stream.Send(&pb.GenericJSONRPCMessage{Jsonrpc: "2.0", TypedId: r.id, Method: "ping", Params: params}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The protocol sends error responses with a background context, once
+	// every request is in-flight
+	var inflight []*transport.BaseJSONRPCRequest
+	for range 3 {
+		select {
+		case r := <-requests:
+			inflight = append(inflight, r)
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected the requests to be handled")
+		}
+	}
+	for _, r := range inflight {
+		var params struct{ Label string }
+		if err := json.Unmarshal(r.Params, &params); err != nil {
+			t.Fatal(err)
+		}
+		e := transport.NewBaseMessageError(&transport.BaseJSONRPCError{
+			Jsonrpc: "2.0",
+			Id:      r.Id,
+			Error:   transport.BaseJSONRPCErrorInner{Code: -32601, Message: params.Label},
+		})
+		if err := tr.Send(context.Background(), e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, r := range []struct {
+		stream pb.JSONRPCService_TransportClient
+		want   map[string]*pb.ID
+	}{
+		{a, map[string]*pb.ID{"a": num}},
+		{b, map[string]*pb.ID{"b": num, "b-str": str}},
+	} {
+		for range r.want {
+			msg, err := r.stream.Recv()
+			if err != nil {
+				t.Fatal(err)
+			}
+			id, ok := r.want[msg.GetError().GetMessage()]
+			if !ok || !proto.Equal(msg.TypedId, id) {
+				t.Errorf("unexpected reply %v", msg)
+			}
+			delete(r.want, msg.GetError().GetMessage())
+		}
+	}
+}
+
+// serve serves tr over an in-memory listener, and returns a connection to it.
+// Messages received by tr are dropped unless it has a message handler.
+func serve(t *testing.T, tr *GrpcServerTransport) *grpc.ClientConn {
+	t.Helper()
+	if tr.onMessage == nil {
+		tr.SetMessageHandler(func(context.Context, *transport.BaseJsonRpcMessage) {})
+	}
+	lis := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer()
+	pb.RegisterJSONRPCServiceServer(gs, tr)
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// open opens a stream on conn, and waits until tr has its session
+func open(t *testing.T, tr *GrpcServerTransport, conn *grpc.ClientConn) pb.JSONRPCService_TransportClient {
+	t.Helper()
+	before := 0
+	tr.sessions.Range(func(_, _ any) bool { before++; return true })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	stream, err := pb.NewJSONRPCServiceClient(conn).Transport(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&pb.GenericJSONRPCMessage{Jsonrpc: "2.0", Method: "notifications/initialized"}); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		n := 0
+		tr.sessions.Range(func(_, _ any) bool { n++; return true })
+		if n > before {
+			return stream
+		}
+	}
+	t.Fatal("the session did not start")
+	return nil
+}
@@ -0,0 +1,138 @@
+package grpc
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/metoro-io/mcp-golang/transport"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/admin"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/audit"
+	pb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/jsonrpc"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/session"
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/tracing"
+)
+
+// grpcSession holds the state of a single Transport stream
+type grpcSession struct {
+	info   *session.Info
+	stream pb.JSONRPCService_TransportServer
+	admin  *admin.Session
+
+	// received and sent count the messages of the session
+	received, sent atomic.Uint64
+
+	// sendMu serializes stream.Send, as the metoro-io protocol answers
+	// requests from concurrent goroutines
+	sendMu sync.Mutex
+
+	mu sync.Mutex
+	// inflight holds the in-flight requests by the message.IDKey of their
+	// client's ID
+	inflight map[string]*inflightRequest
+	// pending counts the in-flight requests which have not been answered yet
+	pending sync.WaitGroup
+}
+
+// inflightRequest is a request which has been handed to the message handler
+// and is waiting for its response
+type inflightRequest struct {
+	sess    *grpcSession
+	request *pb.GenericJSONRPCMessage
+	// id replaces the client's request ID towards the metoro-io protocol,
+	// which keys its state by ID across every session
+	id      transport.RequestId
+	start   time.Time
+	release func()
+	audit   *audit.Record
+	span    tracing.Span
+	ctx     context.Context
+	cancel  context.CancelFunc
+	// cancelled is set once a timeout or the admin service answered the request
+	cancelled bool
+}
+
+func newGrpcSession(info *session.Info, stream pb.JSONRPCService_TransportServer) *grpcSession {
+	return &grpcSession{
+		info:     info,
+		stream:   stream,
+		inflight: make(map[string]*inflightRequest),
+	}
+}
+
+func (s *grpcSession) send(msg *pb.GenericJSONRPCMessage) error {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	return s.stream.Send(msg)
+}
+
+// start records a request as in-flight until its response is sent. It
+// reports false if a request with the same ID is still in-flight, whose
+// response the new one could not be told apart from.
+func (s *grpcSession) start(key string, req *inflightRequest) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.inflight[key]; ok {
+		return false
+	}
+	s.pending.Add(1)
+	s.inflight[key] = req
+	return true
+}
+
+// finish removes a request from the in-flight set once it is answered
+func (s *grpcSession) finish(key string) *inflightRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	req := s.inflight[key]
+	delete(s.inflight, key)
+	if req != nil && !req.cancelled {
+		s.pending.Done()
+	}
+	return req
+}
+
+// cancel marks an in-flight request as answered by a timeout or the admin
+// service. It
+// stays in-flight until the handler's response arrives and is dropped.
+func (s *grpcSession) cancel(key string) *inflightRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	req := s.inflight[key]
+	if req == nil || req.cancelled {
+		return nil
+	}
+	req.cancelled = true
+	s.pending.Done()
+	return req
+}
+
+func (s *grpcSession) lookup(key string) *inflightRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inflight[key]
+}
+
+// close releases every request which is still unanswered when the stream
+// ends, and returns every request which was in-flight
+func (s *grpcSession) close() []*inflightRequest {
+	s.mu.Lock()
+	inflight := s.inflight
+	s.inflight = make(map[string]*inflightRequest)
+	s.mu.Unlock()
+
+	reqs := make([]*inflightRequest, 0, len(inflight))
+	for _, req := range inflight {
+		reqs = append(reqs, req)
+		req.cancel()
+		if req.cancelled {
+			continue
+		}
+		s.pending.Done()
+		req.audit.Abort()
+		tracing.End(req.span, nil)
+		req.release()
+	}
+	return reqs
+}
@@ -0,0 +1,78 @@
+// Package metrics records MCP-level metrics for the gRPC server transports.
+// gRPC's own stats only see one long-lived stream per session, so these
+// metrics look at the JSON-RPC messages carried on it.
+package metrics
+
+import (
+	"time"
+
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/message"
+	pb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/jsonrpc"
+)
+
+// Directions of a conversion failure
+const (
+	DirectionIn  = "in"
+	DirectionOut = "out"
+)
+
+// Outcomes of an answered tools/call request
+const (
+	OutcomeOK        = "ok"
+	OutcomeToolError = "tool_error"
+	OutcomeError     = "error"
+	OutcomeTimeout   = "timeout"
+	OutcomeCancelled = "cancelled"
+)
+
+// Outcome classifies the response sent for a tools/call request
+func Outcome(resp *pb.GenericJSONRPCMessage) string {
+	if e := resp.GetError(); e != nil {
+		switch e.Code {
+		case message.ErrorCodeTimeout:
+			return OutcomeTimeout
+		case message.ErrorCodeCancelled:
+			return OutcomeCancelled
+		}
+		return OutcomeError
+	}
+	if resp.GetResult().GetFields()["isError"].GetBoolValue() {
+		return OutcomeToolError
+	}
+	return OutcomeOK
+}
+
+// Recorder receives the metrics of the server transports
+type Recorder interface {
+	// SessionOpened is called when a Transport stream starts
+	SessionOpened()
+	// SessionClosed is called when a Transport stream ends
+	SessionClosed()
+	// MessageReceived is called for every incoming message. Responses to
+	// server-initiated requests have an empty method.
+	MessageReceived(method string)
+	// MessageSent is called for every outgoing message. Responses carry
+	// the method of the request they answer.
+	MessageSent(method string)
+	// ToolCallDuration is called when a tools/call request is answered,
+	// whatever the outcome of the call
+	ToolCallDuration(tool, outcome string, d time.Duration)
+	// ErrorSent is called for every JSON-RPC error response
+	ErrorSent(method string, code int32)
+	// ConversionFailed is called when a message cannot be converted between
+	// its protobuf and JSON-RPC representations
+	ConversionFailed(direction string)
+}
+
+// Discard is a Recorder which ignores all metrics
+var Discard Recorder = discard{}
+
+type discard struct{}
+
+func (discard) SessionOpened()                                 {}
+func (discard) SessionClosed()                                 {}
+func (discard) MessageReceived(string)                         {}
+func (discard) MessageSent(string)                             {}
+func (discard) ToolCallDuration(string, string, time.Duration) {}
+func (discard) ErrorSent(string, int32)                        {}
+func (discard) ConversionFailed(string)                        {}
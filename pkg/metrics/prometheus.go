@@ -0,0 +1,243 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the tools/call latency histogram buckets, in seconds
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// DefaultMaxLabelValues bounds the label values kept per metric. Methods and
+// tool names come from clients, so further values are folded into "other".
+const DefaultMaxLabelValues = 500
+
+const otherLabel = "other"
+
+// clientLabels are the labels whose values are chosen by clients, and
+// folded into otherLabel once a metric has too many series. The other
+// labels have a small set of values, which are kept apart.
+var clientLabels = map[string]bool{"method": true, "tool": true}
+
+// Prometheus is a Recorder which serves its metrics in the Prometheus text
+// exposition format.
+type Prometheus struct {
+	mu             sync.Mutex
+	buckets        []float64
+	maxLabelValues int
+
+	sessionsActive int64
+	sessionsTotal  uint64
+	received       *vec
+	sent           *vec
+	errors         *vec
+	conversions    *vec
+	toolDurations  *vec
+}
+
+type PrometheusOption func(*Prometheus)
+
+// WithBuckets sets the tools/call latency histogram buckets, in seconds
+func WithBuckets(buckets ...float64) PrometheusOption {
+	return func(p *Prometheus) {
+		p.buckets = append([]float64(nil), buckets...)
+		sort.Float64s(p.buckets)
+	}
+}
+
+// WithMaxLabelValues sets how many distinct label values are kept per metric
+func WithMaxLabelValues(n int) PrometheusOption {
+	return func(p *Prometheus) {
+		p.maxLabelValues = n
+	}
+}
+
+// NewPrometheus creates a Prometheus Recorder
+func NewPrometheus(opts ...PrometheusOption) *Prometheus {
+	p := &Prometheus{
+		buckets:        DefaultBuckets,
+		maxLabelValues: DefaultMaxLabelValues,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	p.received = newVec("mcp_messages_received_total", "Messages received from clients.", "counter", "method")
+	p.sent = newVec("mcp_messages_sent_total", "Messages sent to clients.", "counter", "method")
+	p.errors = newVec("mcp_errors_total", "JSON-RPC error responses sent to clients.", "counter", "method", "code")
+	p.conversions = newVec("mcp_conversion_failures_total", "Messages which failed to convert between protobuf and JSON-RPC.", "counter", "direction")
+	p.toolDurations = newVec("mcp_tool_call_duration_seconds", "Latency of tools/call requests by outcome.", "histogram", "tool", "outcome")
+	return p
+}
+
+func (p *Prometheus) SessionOpened() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sessionsActive++
+	p.sessionsTotal++
+}
+
+func (p *Prometheus) SessionClosed() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sessionsActive--
+}
+
+func (p *Prometheus) MessageReceived(method string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.received.get(p.maxLabelValues, methodLabel(method)).value++
+}
+
+func (p *Prometheus) MessageSent(method string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sent.get(p.maxLabelValues, methodLabel(method)).value++
+}
+
+func (p *Prometheus) ToolCallDuration(tool, outcome string, d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := p.toolDurations.get(p.maxLabelValues, tool, outcome)
+	if s.buckets == nil {
+		s.buckets = make([]uint64, len(p.buckets))
+	}
+	secs := d.Seconds()
+	for i, le := range p.buckets {
+		if secs <= le {
+			s.buckets[i]++
+		}
+	}
+	s.value += secs
+	s.count++
+}
+
+func (p *Prometheus) ErrorSent(method string, code int32) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.errors.get(p.maxLabelValues, methodLabel(method), strconv.Itoa(int(code))).value++
+}
+
+func (p *Prometheus) ConversionFailed(direction string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.conversions.get(p.maxLabelValues, direction).value++
+}
+
+// Handler serves the metrics over HTTP
+func (p *Prometheus) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := p.Write(w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// Write writes the metrics in the Prometheus text exposition format
+func (p *Prometheus) Write(w io.Writer) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# HELP mcp_sessions_active Active MCP sessions.\n# TYPE mcp_sessions_active gauge\nmcp_sessions_active %d\n", p.sessionsActive)
+	fmt.Fprintf(bw, "# HELP mcp_sessions_total MCP sessions opened.\n# TYPE mcp_sessions_total counter\nmcp_sessions_total %d\n", p.sessionsTotal)
+	p.received.write(bw, nil)
+	p.sent.write(bw, nil)
+	p.errors.write(bw, nil)
+	p.conversions.write(bw, nil)
+	p.toolDurations.write(bw, p.buckets)
+	return bw.Flush()
+}
+
+// methodLabel names the responses received from clients, which have no method
+func methodLabel(method string) string {
+	if method == "" {
+		return "response"
+	}
+	return method
+}
+
+// vec is a metric family with one series per combination of label values
+type vec struct {
+	name, help, typ string
+	labels          []string
+	series          map[string]*series
+}
+
+type series struct {
+	values  []string
+	value   float64
+	count   uint64
+	buckets []uint64
+}
+
+func newVec(name, help, typ string, labels ...string) *vec {
+	return &vec{name: name, help: help, typ: typ, labels: labels, series: make(map[string]*series)}
+}
+
+func (v *vec) get(maxValues int, values ...string) *series {
+	key := strings.Join(values, "\xff")
+	if s, ok := v.series[key]; ok {
+		return s
+	}
+	if maxValues > 0 && len(v.series) >= maxValues {
+		for i, l := range v.labels {
+			if clientLabels[l] {
+				values[i] = otherLabel
+			}
+		}
+		key = strings.Join(values, "\xff")
+		if s, ok := v.series[key]; ok {
+			return s
+		}
+	}
+	s := &series{values: values}
+	v.series[key] = s
+	return s
+}
+
+func (v *vec) write(w io.Writer, buckets []float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", v.name, v.help, v.name, v.typ)
+
+	keys := make([]string, 0, len(v.series))
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		s := v.series[k]
+		labels := v.labelPairs(s.values)
+		if v.typ != "histogram" {
+			fmt.Fprintf(w, "%s{%s} %s\n", v.name, labels, formatFloat(s.value))
+			continue
+		}
+		for i, le := range buckets {
+			fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", v.name, labels, formatFloat(le), s.buckets[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", v.name, labels, s.count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", v.name, labels, formatFloat(s.value))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", v.name, labels, s.count)
+	}
+}
+
+func (v *vec) labelPairs(values []string) string {
+	pairs := make([]string, len(v.labels))
+	for i, l := range v.labels {
+		pairs[i] = fmt.Sprintf(`%s="%s"`, l, labelEscaper.Replace(values[i]))
+	}
+	return strings.Join(pairs, ",")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheus_Exposition(t *testing.T) {
+	p := NewPrometheus(WithBuckets(0.1, 1))
+	p.SessionOpened()
+	p.SessionOpened()
+	p.SessionClosed()
+	p.MessageReceived("tools/call")
+	p.MessageReceived("")
+	p.MessageSent("tools/call")
+	p.ErrorSent("tools/call", -32602)
+	p.ConversionFailed(DirectionIn)
+	p.ToolCallDuration(`say "hi"`, OutcomeOK, 500*time.Millisecond)
+	p.ToolCallDuration(`say "hi"`, OutcomeTimeout, 2*time.Second)
+
+	rec := httptest.NewRecorder()
+	p.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+
+	for _, want := range []string{
+		"mcp_sessions_active 1\n",
+		"mcp_sessions_total 2\n",
+		`mcp_messages_received_total{method="tools/call"} 1`,
+		`mcp_messages_received_total{method="response"} 1`,
+		`mcp_errors_total{method="tools/call",code="-32602"} 1`,
+		`mcp_conversion_failures_total{direction="in"} 1`,
+		`mcp_tool_call_duration_seconds_bucket{tool="say \"hi\"",outcome="ok",le="0.1"} 0`,
+		`mcp_tool_call_duration_seconds_bucket{tool="say \"hi\"",outcome="ok",le="1"} 1`,
+		`mcp_tool_call_duration_seconds_bucket{tool="say \"hi\"",outcome="ok",le="+Inf"} 1`,
+		`mcp_tool_call_duration_seconds_sum{tool="say \"hi\"",outcome="ok"} 0.5`,
+		`mcp_tool_call_duration_seconds_bucket{tool="say \"hi\"",outcome="timeout",le="1"} 0`,
+		`mcp_tool_call_duration_seconds_count{tool="say \"hi\"",outcome="timeout"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q in:\n%s", want, body)
+		}
+	}
+}
+
+func TestPrometheus_BoundsLabelValues(t *testing.T) {
+	p := NewPrometheus(WithMaxLabelValues(2))
+	for _, m := range []string{"a", "b", "c", "d"} {
+		p.MessageReceived(m)
+	}
+
+	var b strings.Builder
+	if err := p.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), `mcp_messages_received_total{method="other"} 2`) {
+		t.Errorf("expected excess methods to be folded into 'other':\n%s", b.String())
+	}
+}
+
+func TestPrometheus_KeepsOutcomesOfFoldedTools(t *testing.T) {
+	p := NewPrometheus(WithMaxLabelValues(2))
+	p.ToolCallDuration("a", OutcomeOK, time.Millisecond)
+	p.ToolCallDuration("b", OutcomeOK, time.Millisecond)
+	p.ToolCallDuration("c", OutcomeOK, time.Millisecond)
+	p.ToolCallDuration("d", OutcomeTimeout, time.Millisecond)
+
+	var b strings.Builder
+	if err := p.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`mcp_tool_call_duration_seconds_count{tool="other",outcome="ok"} 1`,
+		`mcp_tool_call_duration_seconds_count{tool="other",outcome="timeout"} 1`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("missing %q in:\n%s", want, b.String())
+		}
+	}
+}
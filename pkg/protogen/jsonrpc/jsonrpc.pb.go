@@ -190,10 +190,14 @@ func (x *GenericJSONRPCMessage) GetError() *JSONRPCError {
 }
 
 type JSONRPCError struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Code          int32                  `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
-	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
-	Data          string                 `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Code    int32                  `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
+	Message string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	// The error's data as a plain string, for peers which predate data_value:
+	// the data itself if it is a string, or else its JSON encoding
+	Data string `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	// The error's data as any JSON value. Takes precedence over data.
+	DataValue     *structpb.Value `protobuf:"bytes,4,opt,name=data_value,json=dataValue,proto3" json:"data_value,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -249,6 +253,13 @@ func (x *JSONRPCError) GetData() string {
 	return ""
 }
 
+func (x *JSONRPCError) GetDataValue() *structpb.Value {
+	if x != nil {
+		return x.DataValue
+	}
+	return nil
+}
+
 var File_jsonrpc_proto protoreflect.FileDescriptor
 
 const file_jsonrpc_proto_rawDesc = "" +
@@ -264,11 +275,13 @@ const file_jsonrpc_proto_rawDesc = "" +
 	"\x06method\x18\x03 \x01(\tR\x06method\x12/\n" +
 	"\x06params\x18\x04 \x01(\v2\x17.google.protobuf.StructR\x06params\x12/\n" +
 	"\x06result\x18\x05 \x01(\v2\x17.google.protobuf.StructR\x06result\x12#\n" +
-	"\x05error\x18\x06 \x01(\v2\r.JSONRPCErrorR\x05error\"P\n" +
+	"\x05error\x18\x06 \x01(\v2\r.JSONRPCErrorR\x05error\"\x87\x01\n" +
 	"\fJSONRPCError\x12\x12\n" +
 	"\x04code\x18\x01 \x01(\x05R\x04code\x12\x18\n" +
 	"\amessage\x18\x02 \x01(\tR\amessage\x12\x12\n" +
-	"\x04data\x18\x03 \x01(\tR\x04data2Q\n" +
+	"\x04data\x18\x03 \x01(\tR\x04data\x125\n" +
+	"\n" +
+	"data_value\x18\x04 \x01(\v2\x16.google.protobuf.ValueR\tdataValue2Q\n" +
 	"\x0eJSONRPCService\x12?\n" +
 	"\tTransport\x12\x16.GenericJSONRPCMessage\x1a\x16.GenericJSONRPCMessage(\x010\x01B?Z=github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/jsonrpcb\x06proto3"
 
@@ -290,19 +303,21 @@ var file_jsonrpc_proto_goTypes = []any{
 	(*GenericJSONRPCMessage)(nil), // 1: GenericJSONRPCMessage
 	(*JSONRPCError)(nil),          // 2: JSONRPCError
 	(*structpb.Struct)(nil),       // 3: google.protobuf.Struct
+	(*structpb.Value)(nil),        // 4: google.protobuf.Value
 }
 var file_jsonrpc_proto_depIdxs = []int32{
 	0, // 0: GenericJSONRPCMessage.typed_id:type_name -> ID
 	3, // 1: GenericJSONRPCMessage.params:type_name -> google.protobuf.Struct
 	3, // 2: GenericJSONRPCMessage.result:type_name -> google.protobuf.Struct
 	2, // 3: GenericJSONRPCMessage.error:type_name -> JSONRPCError
-	1, // 4: JSONRPCService.Transport:input_type -> GenericJSONRPCMessage
-	1, // 5: JSONRPCService.Transport:output_type -> GenericJSONRPCMessage
-	5, // [5:6] is the sub-list for method output_type
-	4, // [4:5] is the sub-list for method input_type
-	4, // [4:4] is the sub-list for extension type_name
-	4, // [4:4] is the sub-list for extension extendee
-	0, // [0:4] is the sub-list for field type_name
+	4, // 4: JSONRPCError.data_value:type_name -> google.protobuf.Value
+	1, // 5: JSONRPCService.Transport:input_type -> GenericJSONRPCMessage
+	1, // 6: JSONRPCService.Transport:output_type -> GenericJSONRPCMessage
+	6, // [6:7] is the sub-list for method output_type
+	5, // [5:6] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
 }
 
 func init() { file_jsonrpc_proto_init() }
@@ -0,0 +1,278 @@
+// Package ratelimit implements per-principal, per-session and per-tool
+// request limits for the gRPC server transports.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrorCode is the JSON-RPC error code returned for over-limit requests
+const ErrorCode = -32005
+
+// ConcurrencyRetryAfter is the retry hint given when a concurrency cap is hit
+const ConcurrencyRetryAfter = time.Second
+
+const methodToolsCall = "tools/call"
+
+// sweepInterval is how often idle buckets are looked for
+const sweepInterval = time.Minute
+
+// Scope identifies which limit rejected a request
+type Scope string
+
+const (
+	ScopePrincipal Scope = "principal"
+	ScopeSession   Scope = "session"
+	ScopeTool      Scope = "tool"
+)
+
+// Rate is a token bucket refilling at PerSecond tokens per second. A zero
+// PerSecond disables the bucket. Burst defaults to ceil(PerSecond).
+type Rate struct {
+	PerSecond float64
+	Burst     int
+}
+
+// Limit combines a request rate with a cap on concurrent tools/call requests.
+// A zero MaxConcurrent disables the cap.
+type Limit struct {
+	Rate
+	MaxConcurrent int
+}
+
+// Config holds the limits applied by a Limiter. Tool applies to each tool on
+// its own, unless Tools overrides the limits of its name.
+type Config struct {
+	Principal Limit
+	Session   Limit
+	Tool      Limit
+	Tools     map[string]Limit
+}
+
+// Request describes a single incoming JSON-RPC request
+type Request struct {
+	Principal string
+	Session   string
+	Method    string
+	Tool      string
+}
+
+// Error is returned when a request exceeds one of the configured limits
+type Error struct {
+	Scope      Scope
+	Key        string
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s limit exceeded for %s %q, retry after %s", e.Reason, e.Scope, e.Key, e.RetryAfter)
+}
+
+// Data returns the retry hint to be sent in the JSON-RPC error's data field
+func (e *Error) Data() map[string]any {
+	return map[string]any{
+		"scope":        string(e.Scope),
+		"reason":       e.Reason,
+		"retryAfterMs": e.RetryAfter.Milliseconds(),
+	}
+}
+
+type key struct {
+	scope Scope
+	id    string
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter enforces a Config. The zero value is not usable, use New instead.
+// A nil *Limiter allows every request.
+type Limiter struct {
+	mu      sync.Mutex
+	cfg     Config
+	now     func() time.Time
+	buckets map[key]*bucket
+	active  map[key]int
+	swept   time.Time
+}
+
+// New creates a Limiter enforcing the given Config
+func New(cfg Config) *Limiter {
+	return &Limiter{
+		cfg:     cfg,
+		now:     time.Now,
+		buckets: make(map[key]*bucket),
+		active:  make(map[key]int),
+	}
+}
+
+// SetConfig replaces the limits at runtime. Existing buckets keep their
+// tokens and refill at the new rate from now on.
+func (l *Limiter) SetConfig(cfg Config) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cfg = cfg
+}
+
+// Config returns the limits currently in effect
+func (l *Limiter) Config() Config {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.cfg
+}
+
+// Acquire admits a request or returns an *Error describing the exceeded
+// limit. The returned release func must be called once the request has been
+// answered; it is safe to call more than once.
+func (l *Limiter) Acquire(r Request) (release func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	type check struct {
+		key   key
+		limit Limit
+	}
+	var checks []check
+	if r.Principal != "" {
+		checks = append(checks, check{key{ScopePrincipal, r.Principal}, l.cfg.Principal})
+	}
+	if r.Session != "" {
+		checks = append(checks, check{key{ScopeSession, r.Session}, l.cfg.Session})
+	}
+	if r.Tool != "" {
+		checks = append(checks, check{key{ScopeTool, r.Tool}, l.toolLimit(r.Tool)})
+	}
+
+	isToolCall := r.Method == methodToolsCall
+	now := l.now()
+	if now.Sub(l.swept) >= sweepInterval {
+		l.sweep(now)
+	}
+
+	// Check everything before taking anything, so a rejected request does
+	// not consume tokens from the scopes which would have admitted it.
+	for _, c := range checks {
+		if isToolCall && c.limit.MaxConcurrent > 0 && l.active[c.key] >= c.limit.MaxConcurrent {
+			return nil, &Error{
+				Scope:      c.key.scope,
+				Key:        c.key.id,
+				Reason:     "concurrency",
+				RetryAfter: ConcurrencyRetryAfter,
+			}
+		}
+		if wait := l.refill(c.key, c.limit.Rate, now); wait > 0 {
+			return nil, &Error{
+				Scope:      c.key.scope,
+				Key:        c.key.id,
+				Reason:     "rate",
+				RetryAfter: wait,
+			}
+		}
+	}
+
+	var held []key
+	for _, c := range checks {
+		if b := l.buckets[c.key]; b != nil && c.limit.PerSecond > 0 {
+			b.tokens--
+		}
+		if isToolCall && c.limit.MaxConcurrent > 0 {
+			l.active[c.key]++
+			held = append(held, c.key)
+		}
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			for _, k := range held {
+				if l.active[k] <= 1 {
+					delete(l.active, k)
+				} else {
+					l.active[k]--
+				}
+			}
+		})
+	}, nil
+}
+
+// CloseSession drops the state kept for a session once its stream has ended
+func (l *Limiter) CloseSession(id string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.buckets, key{ScopeSession, id})
+	delete(l.active, key{ScopeSession, id})
+}
+
+// toolLimit returns the limits of the named tool
+func (l *Limiter) toolLimit(name string) Limit {
+	if own, ok := l.cfg.Tools[name]; ok {
+		return own
+	}
+	return l.cfg.Tool
+}
+
+// sweep drops the buckets which have been idle long enough to refill
+// completely, as a new bucket would be full as well. This bounds the state
+// kept for principals and for calls of made-up tool names.
+func (l *Limiter) sweep(now time.Time) {
+	l.swept = now
+	for k, b := range l.buckets {
+		var rate Rate
+		switch k.scope {
+		case ScopePrincipal:
+			rate = l.cfg.Principal.Rate
+		case ScopeSession:
+			rate = l.cfg.Session.Rate
+		case ScopeTool:
+			rate = l.toolLimit(k.id).Rate
+		}
+		if rate.PerSecond <= 0 || b.tokens+now.Sub(b.last).Seconds()*rate.PerSecond >= burst(rate) {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+// burst returns the capacity of a bucket refilling at rate
+func burst(rate Rate) float64 {
+	if rate.Burst > 0 {
+		return float64(rate.Burst)
+	}
+	return math.Ceil(rate.PerSecond)
+}
+
+// refill tops up the bucket for k and returns how long the caller has to wait
+// for a token, or zero if one is available.
+func (l *Limiter) refill(k key, rate Rate, now time.Time) time.Duration {
+	if rate.PerSecond <= 0 {
+		return 0
+	}
+	full := burst(rate)
+
+	b, ok := l.buckets[k]
+	if !ok {
+		b = &bucket{tokens: full, last: now}
+		l.buckets[k] = b
+	}
+	b.tokens = math.Min(full, b.tokens+now.Sub(b.last).Seconds()*rate.PerSecond)
+	b.last = now
+
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / rate.PerSecond * float64(time.Second))
+}
@@ -0,0 +1,156 @@
+package ratelimit
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestLimiter(cfg Config) (*Limiter, *time.Time) {
+	now := time.Unix(0, 0)
+	l := New(cfg)
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestAcquire_NilLimiterAllows(t *testing.T) {
+	var l *Limiter
+	release, err := l.Acquire(Request{Session: "s", Method: "tools/call"})
+	if err != nil {
+		t.Fatalf("expected nil limiter to allow, got %v", err)
+	}
+	release()
+}
+
+func TestAcquire_SessionRate(t *testing.T) {
+	l, now := newTestLimiter(Config{Session: Limit{Rate: Rate{PerSecond: 2}}})
+	req := Request{Session: "s1", Method: "ping"}
+
+	for i := 0; i < 2; i++ {
+		if _, err := l.Acquire(req); err != nil {
+			t.Fatalf("request %d: unexpected error %v", i, err)
+		}
+	}
+
+	_, err := l.Acquire(req)
+	var limitErr *Error
+	if !errors.As(err, &limitErr) {
+		t.Fatalf("expected *Error, got %v", err)
+	}
+	if limitErr.Scope != ScopeSession || limitErr.Reason != "rate" {
+		t.Errorf("unexpected rejection: %+v", limitErr)
+	}
+	if limitErr.RetryAfter != 500*time.Millisecond {
+		t.Errorf("expected retry after 500ms, got %s", limitErr.RetryAfter)
+	}
+
+	if _, err := l.Acquire(Request{Session: "s2", Method: "ping"}); err != nil {
+		t.Errorf("other sessions must not be affected, got %v", err)
+	}
+
+	*now = now.Add(500 * time.Millisecond)
+	if _, err := l.Acquire(req); err != nil {
+		t.Errorf("expected a token after refill, got %v", err)
+	}
+}
+
+func TestAcquire_RejectionDoesNotConsumeTokens(t *testing.T) {
+	l, _ := newTestLimiter(Config{
+		Session: Limit{Rate: Rate{PerSecond: 1}},
+		Tools: map[string]Limit{
+			"slow": {Rate: Rate{PerSecond: 1}},
+			"fast": {Rate: Rate{PerSecond: 1}},
+		},
+	})
+
+	if _, err := l.Acquire(Request{Session: "s1", Method: "tools/call", Tool: "slow"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Acquire(Request{Session: "s2", Method: "tools/call", Tool: "slow"}); err == nil {
+		t.Fatal("expected tool rate limit")
+	}
+	if _, err := l.Acquire(Request{Session: "s2", Method: "tools/call", Tool: "fast"}); err != nil {
+		t.Errorf("session s2 token should not have been consumed, got %v", err)
+	}
+}
+
+func TestAcquire_ToolConcurrency(t *testing.T) {
+	l, _ := newTestLimiter(Config{
+		Tools: map[string]Limit{"hang": {MaxConcurrent: 1}},
+	})
+	req := Request{Principal: "alice", Session: "s1", Method: "tools/call", Tool: "hang"}
+
+	release, err := l.Acquire(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = l.Acquire(req)
+	var limitErr *Error
+	if !errors.As(err, &limitErr) || limitErr.Reason != "concurrency" || limitErr.Scope != ScopeTool {
+		t.Fatalf("expected tool concurrency rejection, got %v", err)
+	}
+
+	release()
+	release()
+	if _, err := l.Acquire(req); err != nil {
+		t.Errorf("expected slot to be released, got %v", err)
+	}
+}
+
+func TestAcquire_ToolsHaveTheirOwnBuckets(t *testing.T) {
+	l, _ := newTestLimiter(Config{Tool: Limit{Rate: Rate{PerSecond: 1}}})
+
+	if _, err := l.Acquire(Request{Method: "tools/call", Tool: "noisy"}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := l.Acquire(Request{Method: "tools/call", Tool: "noisy"})
+	var limitErr *Error
+	if !errors.As(err, &limitErr) || limitErr.Scope != ScopeTool || limitErr.Key != "noisy" {
+		t.Fatalf("expected the noisy tool to be limited, got %v", err)
+	}
+	if _, err := l.Acquire(Request{Method: "tools/call", Tool: "quiet"}); err != nil {
+		t.Errorf("expected another tool to be admitted, got %v", err)
+	}
+}
+
+func TestAcquire_EvictsIdleBuckets(t *testing.T) {
+	l, now := newTestLimiter(Config{
+		Principal: Limit{Rate: Rate{PerSecond: 1, Burst: 5}},
+		Tool:      Limit{Rate: Rate{PerSecond: 1}},
+	})
+	for _, p := range []string{"alice", "bob"} {
+		if _, err := l.Acquire(Request{Principal: p, Method: "tools/call", Tool: "made-up-" + p}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	*now = now.Add(sweepInterval)
+	if _, err := l.Acquire(Request{Principal: "carol", Method: "ping"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := l.buckets[key{ScopePrincipal, "alice"}]; ok {
+		t.Error("expected the idle principal's bucket to be evicted")
+	}
+	if _, ok := l.buckets[key{ScopeTool, "made-up-alice"}]; ok {
+		t.Error("expected the idle tool's bucket to be evicted")
+	}
+	if len(l.buckets) != 1 {
+		t.Errorf("expected only carol's bucket to remain, got %d", len(l.buckets))
+	}
+}
+
+func TestSetConfig_AppliesAtRuntime(t *testing.T) {
+	l, _ := newTestLimiter(Config{})
+	req := Request{Principal: "bob", Method: "ping"}
+	if _, err := l.Acquire(req); err != nil {
+		t.Fatal(err)
+	}
+
+	l.SetConfig(Config{Principal: Limit{Rate: Rate{PerSecond: 1}}})
+	if _, err := l.Acquire(req); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Acquire(req); err == nil {
+		t.Error("expected the new principal limit to apply")
+	}
+}
@@ -0,0 +1,94 @@
+// Package session holds the per-stream identity shared by the gRPC server
+// transports. Every call to JSONRPCService.Transport is one MCP session.
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// Info describes a single MCP session served over a gRPC stream
+type Info struct {
+	ID        string
+	Principal string
+	Peer      string
+	StartedAt time.Time
+}
+
+// PrincipalFunc returns the authenticated principal for a stream context.
+// An empty string means the caller is anonymous.
+type PrincipalFunc func(ctx context.Context) string
+
+type ctxKey struct{}
+
+type principalKey struct{}
+
+// New creates the session Info for a newly opened stream
+func New(ctx context.Context, principal PrincipalFunc) *Info {
+	if principal == nil {
+		principal = DefaultPrincipal
+	}
+	info := &Info{
+		ID:        NewID(),
+		Principal: principal(ctx),
+		StartedAt: time.Now(),
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		info.Peer = p.Addr.String()
+	}
+	return info
+}
+
+// NewID returns a random session identifier
+func NewID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
+
+// WithInfo stores the session Info in the context
+func WithInfo(ctx context.Context, info *Info) context.Context {
+	return context.WithValue(ctx, ctxKey{}, info)
+}
+
+// FromContext returns the session Info stored in the context, if any
+func FromContext(ctx context.Context) *Info {
+	info, _ := ctx.Value(ctxKey{}).(*Info)
+	return info
+}
+
+// WithPrincipal stores a principal established by an authenticator in the
+// context, taking precedence over the TLS client certificate.
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// DefaultPrincipal identifies the caller by the principal stored with
+// WithPrincipal, or else by the common name of its verified TLS client
+// certificate. Streams without either are anonymous.
+func DefaultPrincipal(ctx context.Context) string {
+	if principal, ok := ctx.Value(principalKey{}).(string); ok && principal != "" {
+		return principal
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.AuthInfo == nil {
+		return ""
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return ""
+	}
+	for _, chain := range tlsInfo.State.VerifiedChains {
+		if len(chain) > 0 {
+			return chain[0].Subject.CommonName
+		}
+	}
+	return ""
+}
@@ -0,0 +1,104 @@
+// Package timeout bounds how long the server transports wait for a request
+// handler before answering with a JSON-RPC timeout error.
+package timeout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/message"
+	pb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/jsonrpc"
+)
+
+// ErrorCode is the JSON-RPC error code for requests which timed out
+const ErrorCode = message.ErrorCodeTimeout
+
+// MetaKey is the "_meta" field in which a client can ask for a shorter
+// timeout than the server's, in milliseconds
+const MetaKey = "timeoutMs"
+
+// Config holds the time budgets of requests. A zero budget is unbounded.
+type Config struct {
+	// Default applies to every request without a more specific budget
+	Default time.Duration
+	// Methods overrides the default per MCP method
+	Methods map[string]time.Duration
+	// Tools overrides the method budget of tools/call per tool
+	Tools map[string]time.Duration
+}
+
+// Budget returns how long a request may take, or 0 if it is unbounded. A
+// timeout from the request's "_meta" can only shorten the configured one.
+// It is safe to call on a nil Config.
+func (c *Config) Budget(m *pb.GenericJSONRPCMessage) time.Duration {
+	var d time.Duration
+	if c != nil {
+		d = c.Default
+		if v, ok := c.Methods[m.GetMethod()]; ok {
+			d = v
+		}
+		if v, ok := c.Tools[message.ToolName(m)]; ok && m.GetMethod() == message.MethodToolsCall {
+			d = v
+		}
+	}
+	if v := metaTimeout(m); v > 0 && (d == 0 || v < d) {
+		d = v
+	}
+	return d
+}
+
+func metaTimeout(m *pb.GenericJSONRPCMessage) time.Duration {
+	ms := m.GetParams().GetFields()["_meta"].GetStructValue().GetFields()[MetaKey].GetNumberValue()
+	if ms <= 0 {
+		return 0
+	}
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// Error is the cause of a request context whose budget was exceeded
+type Error struct {
+	Method  string
+	Tool    string
+	Timeout time.Duration
+}
+
+func (e *Error) Error() string {
+	if e.Tool != "" {
+		return fmt.Sprintf("tool %q timed out after %s", e.Tool, e.Timeout)
+	}
+	return fmt.Sprintf("%s timed out after %s", e.Method, e.Timeout)
+}
+
+// Data is attached to the JSON-RPC error response
+func (e *Error) Data() map[string]any {
+	return map[string]any{
+		"timeoutMs": e.Timeout.Milliseconds(),
+	}
+}
+
+// WithTimeout derives the handler context of a request, which is cancelled
+// with an *Error cause once the request's budget is exceeded. It also ends
+// when the parent, e.g. the stream with its gRPC deadline, does.
+func WithTimeout(ctx context.Context, c *Config, m *pb.GenericJSONRPCMessage) (context.Context, context.CancelFunc) {
+	d := c.Budget(m)
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeoutCause(ctx, d, &Error{
+		Method:  m.GetMethod(),
+		Tool:    message.ToolName(m),
+		Timeout: d,
+	})
+}
+
+// Exceeded returns the timeout of a request context whose budget was
+// exceeded, or nil
+func Exceeded(ctx context.Context) *Error {
+	var e *Error
+	if errors.As(context.Cause(ctx), &e) {
+		return e
+	}
+	return nil
+}
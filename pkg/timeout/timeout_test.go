@@ -0,0 +1,66 @@
+package timeout
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/jsonrpc"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func request(t *testing.T, method string, params map[string]any) *pb.GenericJSONRPCMessage {
+	t.Helper()
+	p, err := structpb.NewStruct(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &pb.GenericJSONRPCMessage{TypedId: &pb.ID{Kind: &pb.ID_Num{Num: 1}}, Method: method, Params: p}
+}
+
+func TestBudget(t *testing.T) {
+	c := &Config{
+		Default: 30 * time.Second,
+		Methods: map[string]time.Duration{"tools/call": 10 * time.Second, "resources/read": 0},
+		Tools:   map[string]time.Duration{"slow": time.Minute},
+	}
+
+	tests := []struct {
+		name string
+		c    *Config
+		m    *pb.GenericJSONRPCMessage
+		want time.Duration
+	}{
+		{"default", c, request(t, "prompts/get", nil), 30 * time.Second},
+		{"method", c, request(t, "tools/call", map[string]any{"name": "echo"}), 10 * time.Second},
+		{"unbounded method", c, request(t, "resources/read", nil), 0},
+		{"tool", c, request(t, "tools/call", map[string]any{"name": "slow"}), time.Minute},
+		{"shorter meta", c, request(t, "tools/call", map[string]any{"name": "slow", "_meta": map[string]any{"timeoutMs": 500}}), 500 * time.Millisecond},
+		{"longer meta", c, request(t, "prompts/get", map[string]any{"_meta": map[string]any{"timeoutMs": 60000}}), 30 * time.Second},
+		{"meta without config", nil, request(t, "ping", map[string]any{"_meta": map[string]any{"timeoutMs": 250}}), 250 * time.Millisecond},
+		{"no config", nil, request(t, "ping", nil), 0},
+	}
+	for _, tt := range tests {
+		if got := tt.c.Budget(tt.m); got != tt.want {
+			t.Errorf("%s: Budget() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	c := &Config{Tools: map[string]time.Duration{"slow": 10 * time.Millisecond}}
+
+	ctx, cancel := WithTimeout(context.Background(), c, request(t, "tools/call", map[string]any{"name": "slow"}))
+	defer cancel()
+	<-ctx.Done()
+	e := Exceeded(ctx)
+	if e == nil || e.Tool != "slow" || e.Data()["timeoutMs"] != int64(10) {
+		t.Errorf("expected the tool timeout as the cause, got %v", context.Cause(ctx))
+	}
+
+	ctx, cancel = WithTimeout(context.Background(), c, request(t, "tools/list", nil))
+	cancel()
+	if Exceeded(ctx) != nil {
+		t.Error("expected a cancelled request not to count as timed out")
+	}
+}
@@ -0,0 +1,167 @@
+// Package tlsreload serves TLS material from files and picks up rotated
+// certificates without restarting the gRPC server.
+package tlsreload
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// DefaultPollInterval is how often the files are checked for changes
+const DefaultPollInterval = 10 * time.Second
+
+// Reloader loads a certificate, key and optional client CA bundle from files
+// and hands out the most recent material through GetConfigForClient.
+type Reloader struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+	interval     time.Duration
+	onError      func(error)
+
+	mu       sync.RWMutex
+	config   *tls.Config
+	checksum [sha256.Size]byte
+}
+
+type Option func(*Reloader)
+
+// WithClientCA requires clients to present a certificate signed by one of
+// the CAs in the given PEM file.
+func WithClientCA(file string) Option {
+	return func(r *Reloader) {
+		r.clientCAFile = file
+	}
+}
+
+// WithPollInterval sets how often the files are checked for changes
+func WithPollInterval(d time.Duration) Option {
+	return func(r *Reloader) {
+		r.interval = d
+	}
+}
+
+// WithErrorHandler sets the handler for failed reloads. The previously
+// loaded material stays in use when a reload fails.
+func WithErrorHandler(handler func(error)) Option {
+	return func(r *Reloader) {
+		r.onError = handler
+	}
+}
+
+// New creates a Reloader and loads the initial TLS material
+func New(certFile, keyFile string, opts ...Option) (*Reloader, error) {
+	r := &Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: DefaultPollInterval,
+		onError: func(err error) {
+			fmt.Fprintf(os.Stderr, "TLS reload failed: %v\n", err)
+		},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the files again and swaps in the new material if it changed
+func (r *Reloader) Reload() error {
+	certPEM, err := os.ReadFile(r.certFile)
+	if err != nil {
+		return fmt.Errorf("read certificate: %w", err)
+	}
+	keyPEM, err := os.ReadFile(r.keyFile)
+	if err != nil {
+		return fmt.Errorf("read key: %w", err)
+	}
+	var caPEM []byte
+	if r.clientCAFile != "" {
+		if caPEM, err = os.ReadFile(r.clientCAFile); err != nil {
+			return fmt.Errorf("read client CA: %w", err)
+		}
+	}
+
+	checksum := sha256.Sum256(bytes.Join([][]byte{certPEM, keyPEM, caPEM}, []byte{0}))
+	r.mu.RLock()
+	unchanged := r.config != nil && checksum == r.checksum
+	r.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return fmt.Errorf("load key pair: %w", err)
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if r.clientCAFile != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return fmt.Errorf("no certificates found in client CA file %s", r.clientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.config = config
+	r.checksum = checksum
+	return nil
+}
+
+// Watch polls the files for changes until the context is cancelled
+func (r *Reloader) Watch(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Reload(); err != nil && r.onError != nil {
+				r.onError(err)
+			}
+		}
+	}
+}
+
+// GetConfigForClient returns the current TLS config, to be used as
+// tls.Config.GetConfigForClient
+func (r *Reloader) GetConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.config, nil
+}
+
+// TLSConfig returns a server TLS config which always serves the current material
+func (r *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		GetConfigForClient: r.GetConfigForClient,
+	}
+}
+
+// Credentials returns gRPC server credentials backed by the Reloader
+func (r *Reloader) Credentials() credentials.TransportCredentials {
+	return credentials.NewTLS(r.TLSConfig())
+}
@@ -0,0 +1,119 @@
+package tlsreload
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeKeyPair(t *testing.T, dir, cn string) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IsCA:         true,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = filepath.Join(dir, "tls.crt")
+	keyFile = filepath.Join(dir, "tls.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func servedCommonName(t *testing.T, r *Reloader) string {
+	t.Helper()
+	cfg, err := r.TLSConfig().GetConfigForClient(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cfg.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestReload_PicksUpRotatedCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeKeyPair(t, dir, "first")
+
+	r, err := New(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cn := servedCommonName(t, r); cn != "first" {
+		t.Fatalf("expected 'first', got %q", cn)
+	}
+
+	writeKeyPair(t, dir, "second")
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if cn := servedCommonName(t, r); cn != "second" {
+		t.Errorf("expected 'second' after rotation, got %q", cn)
+	}
+}
+
+func TestReload_KeepsPreviousMaterialOnError(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeKeyPair(t, dir, "good")
+
+	r, err := New(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(keyFile, []byte("garbage"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err == nil {
+		t.Fatal("expected reload of a broken key to fail")
+	}
+	if cn := servedCommonName(t, r); cn != "good" {
+		t.Errorf("expected previous certificate to stay in use, got %q", cn)
+	}
+}
+
+func TestNew_ClientCARequiresClientCerts(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeKeyPair(t, dir, "server")
+
+	r, err := New(certFile, keyFile, WithClientCA(certFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, _ := r.GetConfigForClient(nil)
+	if cfg.ClientAuth != tls.RequireAndVerifyClientCert || cfg.ClientCAs == nil {
+		t.Errorf("expected client certificates to be required, got %v", cfg.ClientAuth)
+	}
+}
@@ -0,0 +1,91 @@
+package tracing
+
+import (
+	"context"
+	"strconv"
+
+	pb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/jsonrpc"
+)
+
+// Span attribute keys set by the server transports
+const (
+	AttrMethod    = "mcp.method.name"
+	AttrSessionID = "mcp.session.id"
+	AttrRequestID = "jsonrpc.request.id"
+	AttrToolName  = "gen_ai.tool.name"
+)
+
+// Tracer creates the spans of JSON-RPC requests. It is the extension point
+// for tracing libraries such as OpenTelemetry.
+type Tracer interface {
+	// Start starts a span for a request. The parent is the trace context
+	// received from the client and is invalid if there was none.
+	Start(ctx context.Context, name string, parent TraceContext, attrs map[string]string) (context.Context, Span)
+}
+
+// Span is a request span started by a Tracer
+type Span interface {
+	// TraceContext is propagated to the request handler as its trace context
+	TraceContext() TraceContext
+	// SetError records the JSON-RPC error the request was answered with
+	SetError(code int32, message string)
+	// End is called once the request has been answered or abandoned
+	End()
+}
+
+// StartRequest starts the span of a request and stores the trace context the
+// handler should continue in the returned context. Without a Tracer the
+// trace context received from the client is passed through and the
+// returned Span is nil.
+func StartRequest(ctx context.Context, t Tracer, name string, parent TraceContext, attrs map[string]string) (context.Context, Span) {
+	if t == nil {
+		if parent.Valid() {
+			ctx = WithTraceContext(ctx, parent)
+		}
+		return ctx, nil
+	}
+	ctx, span := t.Start(ctx, name, parent, attrs)
+	if tc := span.TraceContext(); tc.Valid() {
+		ctx = WithTraceContext(ctx, tc)
+	}
+	return ctx, span
+}
+
+// End records the error of the response, if any, and ends the span. A nil
+// response means the request was abandoned. It is a no-op for a nil Span.
+func End(span Span, resp *pb.GenericJSONRPCMessage) {
+	if span == nil {
+		return
+	}
+	if e := resp.GetError(); e != nil {
+		span.SetError(e.Code, e.Message)
+	}
+	span.End()
+}
+
+// RequestAttributes returns the span attributes of a request
+func RequestAttributes(sessionID, tool string, m *pb.GenericJSONRPCMessage) map[string]string {
+	attrs := map[string]string{
+		AttrMethod:    m.GetMethod(),
+		AttrSessionID: sessionID,
+	}
+	switch id := m.GetTypedId().GetKind().(type) {
+	case *pb.ID_Num:
+		attrs[AttrRequestID] = strconv.FormatInt(id.Num, 10)
+	case *pb.ID_Str:
+		attrs[AttrRequestID] = id.Str
+	}
+	if tool != "" {
+		attrs[AttrToolName] = tool
+	}
+	return attrs
+}
+
+// SpanName names the span of a request after its method and, for tool
+// calls, the tool.
+func SpanName(method, tool string) string {
+	if tool != "" {
+		return method + " " + tool
+	}
+	return method
+}
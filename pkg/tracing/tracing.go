@@ -0,0 +1,176 @@
+// Package tracing propagates W3C trace context between gRPC metadata, the
+// MCP "_meta" of each request and the handler context, and creates a span
+// per JSON-RPC request through a pluggable Tracer.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Header and _meta keys carrying the trace context
+const (
+	TraceParentKey = "traceparent"
+	TraceStateKey  = "tracestate"
+)
+
+// TraceContext is a W3C trace context
+type TraceContext struct {
+	TraceParent string
+	TraceState  string
+}
+
+// Parse validates a traceparent header of version 00
+func Parse(traceparent, tracestate string) (TraceContext, bool) {
+	tc := TraceContext{TraceParent: strings.ToLower(strings.TrimSpace(traceparent)), TraceState: strings.TrimSpace(tracestate)}
+	if !tc.Valid() {
+		return TraceContext{}, false
+	}
+	return tc, true
+}
+
+// Valid reports whether the traceparent is well formed
+func (tc TraceContext) Valid() bool {
+	parts := strings.Split(tc.TraceParent, "-")
+	if len(parts) != 4 || parts[0] != "00" {
+		return false
+	}
+	for i, size := range []int{2, 32, 16, 2} {
+		if len(parts[i]) != size || !isHex(parts[i]) {
+			return false
+		}
+	}
+	// All-zero trace and span IDs are invalid
+	return strings.Trim(parts[1], "0") != "" && strings.Trim(parts[2], "0") != ""
+}
+
+// TraceID returns the trace ID of a valid trace context
+func (tc TraceContext) TraceID() string {
+	if !tc.Valid() {
+		return ""
+	}
+	return tc.TraceParent[3:35]
+}
+
+// SpanID returns the parent span ID of a valid trace context
+func (tc TraceContext) SpanID() string {
+	if !tc.Valid() {
+		return ""
+	}
+	return tc.TraceParent[36:52]
+}
+
+// Sampled reports whether the sampled flag is set
+func (tc TraceContext) Sampled() bool {
+	if !tc.Valid() {
+		return false
+	}
+	var flags byte
+	fmt.Sscanf(tc.TraceParent[53:], "%02x", &flags)
+	return flags&0x01 == 1
+}
+
+// Child returns a trace context for a new span below tc. An invalid parent
+// starts a new sampled trace.
+func (tc TraceContext) Child() TraceContext {
+	if !tc.Valid() {
+		return TraceContext{TraceParent: fmt.Sprintf("00-%s-%s-01", randomHex(16), randomHex(8))}
+	}
+	return TraceContext{
+		TraceParent: fmt.Sprintf("00-%s-%s-%s", tc.TraceID(), randomHex(8), tc.TraceParent[53:]),
+		TraceState:  tc.TraceState,
+	}
+}
+
+// FromMetadata extracts the trace context from incoming gRPC metadata
+func FromMetadata(ctx context.Context) (TraceContext, bool) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return Parse(first(md.Get(TraceParentKey)), first(md.Get(TraceStateKey)))
+}
+
+// FromMeta extracts the trace context from the "_meta" of request params
+func FromMeta(params *structpb.Struct) (TraceContext, bool) {
+	meta := params.GetFields()["_meta"].GetStructValue().GetFields()
+	return Parse(meta[TraceParentKey].GetStringValue(), meta[TraceStateKey].GetStringValue())
+}
+
+// Extract returns the trace context of a request. The request's "_meta"
+// takes precedence over the metadata of the stream it was received on.
+func Extract(ctx context.Context, params *structpb.Struct) (TraceContext, bool) {
+	if tc, ok := FromMeta(params); ok {
+		return tc, true
+	}
+	return FromMetadata(ctx)
+}
+
+// InjectMeta stores the trace context in the "_meta" of request params,
+// keeping any other "_meta" fields.
+func InjectMeta(params *structpb.Struct, tc TraceContext) *structpb.Struct {
+	if params == nil {
+		params = &structpb.Struct{}
+	}
+	if params.Fields == nil {
+		params.Fields = map[string]*structpb.Value{}
+	}
+	meta := params.Fields["_meta"].GetStructValue()
+	if meta == nil {
+		meta = &structpb.Struct{}
+		params.Fields["_meta"] = structpb.NewStructValue(meta)
+	}
+	if meta.Fields == nil {
+		meta.Fields = map[string]*structpb.Value{}
+	}
+	meta.Fields[TraceParentKey] = structpb.NewStringValue(tc.TraceParent)
+	if tc.TraceState != "" {
+		meta.Fields[TraceStateKey] = structpb.NewStringValue(tc.TraceState)
+	}
+	return params
+}
+
+type ctxKey struct{}
+
+// WithTraceContext stores the trace context in the context
+func WithTraceContext(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, ctxKey{}, tc)
+}
+
+// FromContext returns the trace context of the request being handled.
+// Handlers propagate it to downstream calls to continue the trace.
+func FromContext(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(ctxKey{}).(TraceContext)
+	return tc, ok
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	for {
+		if _, err := rand.Read(b); err != nil {
+			panic(err)
+		}
+		if s := hex.EncodeToString(b); strings.Trim(s, "0") != "" {
+			return s
+		}
+	}
+}
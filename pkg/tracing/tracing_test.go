@@ -0,0 +1,107 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/jsonrpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	streamParent  = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	requestParent = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00"
+)
+
+func TestParse(t *testing.T) {
+	for traceparent, valid := range map[string]bool{
+		streamParent: true,
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00F067AA0BA902B7-01": true,
+		"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01": false,
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01": false,
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01": false,
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7":    false,
+		"00-4bf92f3577b34da6a3ce929d0e0e473g-00f067aa0ba902b7-01": false,
+		"": false,
+	} {
+		if _, ok := Parse(traceparent, ""); ok != valid {
+			t.Errorf("Parse(%q) valid = %v, want %v", traceparent, ok, valid)
+		}
+	}
+}
+
+func TestChild(t *testing.T) {
+	parent, _ := Parse(requestParent, "vendor=value")
+	child := parent.Child()
+	if child.TraceID() != parent.TraceID() || child.SpanID() == parent.SpanID() {
+		t.Errorf("expected a new span in the same trace, got %q", child.TraceParent)
+	}
+	if child.Sampled() || child.TraceState != "vendor=value" {
+		t.Errorf("expected flags and tracestate to be kept, got %+v", child)
+	}
+
+	root := TraceContext{}.Child()
+	if !root.Valid() || !root.Sampled() {
+		t.Errorf("expected a new sampled trace, got %q", root.TraceParent)
+	}
+}
+
+func TestExtract_MetaTakesPrecedence(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(TraceParentKey, streamParent))
+
+	tc, ok := Extract(ctx, nil)
+	if !ok || tc.TraceParent != streamParent {
+		t.Errorf("expected the stream trace context, got %+v", tc)
+	}
+
+	params, _ := structpb.NewStruct(map[string]any{"name": "echo"})
+	params = InjectMeta(params, TraceContext{TraceParent: requestParent})
+	tc, ok = Extract(ctx, params)
+	if !ok || tc.TraceParent != requestParent {
+		t.Errorf("expected the _meta trace context, got %+v", tc)
+	}
+	if params.Fields["name"].GetStringValue() != "echo" {
+		t.Error("expected InjectMeta to keep the other params")
+	}
+}
+
+type testTracer struct{ spans []*testSpan }
+
+type testSpan struct {
+	name  string
+	tc    TraceContext
+	code  int32
+	ended bool
+}
+
+func (t *testTracer) Start(ctx context.Context, name string, parent TraceContext, _ map[string]string) (context.Context, Span) {
+	s := &testSpan{name: name, tc: parent.Child()}
+	t.spans = append(t.spans, s)
+	return ctx, s
+}
+
+func (s *testSpan) TraceContext() TraceContext    { return s.tc }
+func (s *testSpan) SetError(code int32, _ string) { s.code = code }
+func (s *testSpan) End()                          { s.ended = true }
+
+func TestStartRequest(t *testing.T) {
+	parent, _ := Parse(requestParent, "")
+
+	ctx, span := StartRequest(context.Background(), nil, "tools/list", parent, nil)
+	if tc, _ := FromContext(ctx); span != nil || tc != parent {
+		t.Errorf("expected the parent to be passed through without a tracer, got %+v", tc)
+	}
+
+	tracer := &testTracer{}
+	ctx, span = StartRequest(context.Background(), tracer, SpanName("tools/call", "echo"), parent, nil)
+	tc, _ := FromContext(ctx)
+	if tc.TraceID() != parent.TraceID() || tc.SpanID() == parent.SpanID() {
+		t.Errorf("expected the handler to continue in the request span, got %+v", tc)
+	}
+
+	End(span, &pb.GenericJSONRPCMessage{Error: &pb.JSONRPCError{Code: -32603}})
+	if s := tracer.spans[0]; s.name != "tools/call echo" || !s.ended || s.code != -32603 {
+		t.Errorf("unexpected span: %+v", s)
+	}
+}
@@ -0,0 +1,114 @@
+package transcript
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/codec"
+	pb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/jsonrpc"
+)
+
+// Difference is a value which differs between a recorded and a replayed message
+type Difference struct {
+	// Path of the value, e.g. "$.result.content[0].text"
+	Path     string
+	Recorded string
+	Actual   string
+}
+
+func (d Difference) String() string {
+	return fmt.Sprintf("%s: recorded %s, got %s", d.Path, d.Recorded, d.Actual)
+}
+
+// Diff compares the JSON-RPC representations of two messages. Values at the
+// ignored paths, or below them, are not compared.
+func Diff(recorded, actual *pb.GenericJSONRPCMessage, ignore ...string) ([]Difference, error) {
+	a, err := toValue(recorded)
+	if err != nil {
+		return nil, err
+	}
+	b, err := toValue(actual)
+	if err != nil {
+		return nil, err
+	}
+	var diffs []Difference
+	diff("$", a, b, ignore, &diffs)
+	return diffs, nil
+}
+
+func toValue(m *pb.GenericJSONRPCMessage) (any, error) {
+	b, err := codec.Encode(m)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v any
+	return v, dec.Decode(&v)
+}
+
+func diff(path string, a, b any, ignore []string, out *[]Difference) {
+	if ignored(path, ignore) {
+		return
+	}
+	switch av := a.(type) {
+	case map[string]any:
+		bv, ok := b.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(av)+len(bv))
+		for k := range av {
+			keys = append(keys, k)
+		}
+		for k := range bv {
+			if _, ok := av[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			diff(path+"."+k, av[k], bv[k], ignore, out)
+		}
+		return
+	case []any:
+		bv, ok := b.([]any)
+		if !ok {
+			break
+		}
+		for i := 0; i < max(len(av), len(bv)); i++ {
+			var x, y any
+			if i < len(av) {
+				x = av[i]
+			}
+			if i < len(bv) {
+				y = bv[i]
+			}
+			diff(fmt.Sprintf("%s[%d]", path, i), x, y, ignore, out)
+		}
+		return
+	}
+	if ja, jb := encode(a), encode(b); ja != jb {
+		*out = append(*out, Difference{Path: path, Recorded: ja, Actual: jb})
+	}
+}
+
+func ignored(path string, ignore []string) bool {
+	for _, p := range ignore {
+		if path == p || strings.HasPrefix(path, p+".") || strings.HasPrefix(path, p+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+func encode(v any) string {
+	if v == nil {
+		return "<missing>"
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,158 @@
+// Package transcript records the messages of MCP sessions to JSON-lines
+// transcripts, which can be replayed against a server to reproduce a bug
+// report as a regression test.
+package transcript
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/codec"
+	pb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/jsonrpc"
+)
+
+// Directions of a recorded message
+const (
+	DirectionToServer = "to_server"
+	DirectionToClient = "to_client"
+)
+
+// Entry is a single line of a transcript
+type Entry struct {
+	Time      time.Time       `json:"time"`
+	SessionID string          `json:"sessionId"`
+	Direction string          `json:"direction"`
+	Message   json.RawMessage `json:"message"`
+}
+
+// Decode returns the recorded message
+func (e Entry) Decode() (*pb.GenericJSONRPCMessage, error) {
+	return codec.Decode(e.Message)
+}
+
+// Recorder writes every message of the recorded sessions as a line of JSON
+type Recorder struct {
+	mu      sync.Mutex
+	w       io.Writer
+	enc     *json.Encoder
+	onError func(error)
+	now     func() time.Time
+}
+
+type Option func(*Recorder)
+
+// WithErrorHandler sets the handler for messages which could not be recorded
+func WithErrorHandler(handler func(error)) Option {
+	return func(r *Recorder) {
+		r.onError = handler
+	}
+}
+
+// New creates a Recorder writing to w
+func New(w io.Writer, opts ...Option) *Recorder {
+	r := &Recorder{
+		w:   w,
+		enc: json.NewEncoder(w),
+		onError: func(err error) {
+			fmt.Fprintf(os.Stderr, "failed to record transcript: %v\n", err)
+		},
+		now: time.Now,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// NewFile creates a Recorder appending to the file at path
+func NewFile(path string, opts ...Option) (*Recorder, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return New(f, opts...), nil
+}
+
+// Record writes a message of a session. It is a no-op for a nil Recorder.
+func (r *Recorder) Record(sessionID, direction string, m *pb.GenericJSONRPCMessage) {
+	if r == nil {
+		return
+	}
+	b, err := codec.Encode(m)
+	if err != nil {
+		r.onError(err)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err = r.enc.Encode(Entry{
+		Time:      r.now().UTC(),
+		SessionID: sessionID,
+		Direction: direction,
+		Message:   b,
+	})
+	if err != nil {
+		r.onError(err)
+	}
+}
+
+// Close closes the underlying writer if it is closable
+func (r *Recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c, ok := r.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Read reads all entries of a transcript
+func Read(rd io.Reader) ([]Entry, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(rd)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// ReadFile reads all entries of the transcript at path
+func ReadFile(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// Sessions returns the IDs of the sessions of a transcript, in the order
+// they first appear
+func Sessions(entries []Entry) []string {
+	var ids []string
+	seen := map[string]bool{}
+	for _, e := range entries {
+		if !seen[e.SessionID] {
+			seen[e.SessionID] = true
+			ids = append(ids, e.SessionID)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,74 @@
+package transcript
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/rustycl0ck/mcp-grpc-transport/pkg/message"
+	pb "github.com/rustycl0ck/mcp-grpc-transport/pkg/protogen/jsonrpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestRecorder_RoundTrip(t *testing.T) {
+	params, _ := structpb.NewStruct(map[string]any{"name": "echo", "arguments": map[string]any{"n": 1.5}})
+	msgs := []*pb.GenericJSONRPCMessage{
+		{Jsonrpc: "2.0", TypedId: &pb.ID{Kind: &pb.ID_Num{Num: 7}}, Method: "tools/call", Params: params},
+		{Jsonrpc: "2.0", Method: "notifications/initialized"},
+		message.NewError(&pb.ID{Kind: &pb.ID_Str{Str: "a"}}, -32005, "rate limited", map[string]any{"retryAfterMs": 100}),
+	}
+
+	var buf bytes.Buffer
+	r := New(&buf)
+	r.Record("s1", DirectionToServer, msgs[0])
+	r.Record("s1", DirectionToServer, msgs[1])
+	r.Record("s2", DirectionToClient, msgs[2])
+
+	entries, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || entries[2].Direction != DirectionToClient {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+	if ids := Sessions(entries); len(ids) != 2 || ids[0] != "s1" {
+		t.Errorf("unexpected sessions: %v", ids)
+	}
+	for i, e := range entries {
+		m, err := e.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !proto.Equal(m, msgs[i]) {
+			t.Errorf("entry %d: got %v, want %v", i, m, msgs[i])
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	result := func(text, version string) *pb.GenericJSONRPCMessage {
+		r, _ := structpb.NewStruct(map[string]any{
+			"content":    []any{map[string]any{"type": "text", "text": text}},
+			"serverInfo": map[string]any{"version": version},
+		})
+		return &pb.GenericJSONRPCMessage{Jsonrpc: "2.0", TypedId: &pb.ID{Kind: &pb.ID_Num{Num: 1}}, Result: r}
+	}
+
+	diffs, err := Diff(result("hi", "1.0"), result("hi", "1.1"), "$.result.serverInfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 0 {
+		t.Errorf("expected ignored paths to be skipped, got %v", diffs)
+	}
+
+	diffs, _ = Diff(result("hi", "1.0"), result("bye", "1.0"))
+	if len(diffs) != 1 || diffs[0].Path != "$.result.content[0].text" {
+		t.Errorf("unexpected differences: %v", diffs)
+	}
+
+	diffs, _ = Diff(result("hi", "1.0"), message.NewError(&pb.ID{Kind: &pb.ID_Num{Num: 1}}, -32603, "boom", nil))
+	if len(diffs) != 2 {
+		t.Errorf("expected a missing result and an unexpected error, got %v", diffs)
+	}
+}